@@ -0,0 +1,281 @@
+package bitmap
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	ARRAY_MAX   = 4096 // above this cardinality, a container switches from a sorted array to a bitset
+	BITSET_LEN  = 1 << 16 / 64
+	CONTAINER_W = 16 // the low bits of a value are stored inside its container
+)
+
+/*
+Bitmap is a compressed set of uint64 in the manner of a roaring bitmap:
+the values sharing the same high 48 bits are grouped inside a container holding their low 16 bits,
+as a sorted array when the container is sparse, as a bitset when it is dense
+*/
+type Bitmap struct {
+	keys       []uint64 // high bits of the containers, in ascending order
+	containers []*container
+}
+
+type container struct {
+	array  []uint16
+	bitset []uint64 // nil while the container is an array
+	card   int
+}
+
+/*
+New returns an empty Bitmap
+*/
+func New() *Bitmap {
+	return &Bitmap{}
+}
+
+/*
+FromOffsets returns the Bitmap of the offsets returned by the lookups of an index
+*/
+func FromOffsets(offsets []int) *Bitmap {
+	b := New()
+	for _, o := range offsets {
+		b.Add(uint64(o))
+	}
+	return b
+}
+
+/*
+Add inserts v in the bitmap
+*/
+func (b *Bitmap) Add(v uint64) {
+	high, low := v>>CONTAINER_W, uint16(v)
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= high })
+	if i == len(b.keys) || b.keys[i] != high {
+		b.keys = append(b.keys, 0)
+		copy(b.keys[i+1:], b.keys[i:])
+		b.keys[i] = high
+		b.containers = append(b.containers, nil)
+		copy(b.containers[i+1:], b.containers[i:])
+		b.containers[i] = &container{}
+	}
+	b.containers[i].add(low)
+}
+
+/*
+Contains tells if v is in the bitmap
+*/
+func (b *Bitmap) Contains(v uint64) bool {
+	high, low := v>>CONTAINER_W, uint16(v)
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= high })
+	return i < len(b.keys) && b.keys[i] == high && b.containers[i].contains(low)
+}
+
+/*
+Cardinality returns the number of values in the bitmap
+*/
+func (b *Bitmap) Cardinality() int {
+	n := 0
+	for _, c := range b.containers {
+		n += c.card
+	}
+	return n
+}
+
+/*
+ToArray returns the values in ascending order
+*/
+func (b *Bitmap) ToArray() []uint64 {
+	values := make([]uint64, 0, b.Cardinality())
+	for i, c := range b.containers {
+		for _, low := range c.values() {
+			values = append(values, b.keys[i]<<CONTAINER_W|uint64(low))
+		}
+	}
+	return values
+}
+
+/*
+Offsets returns the values in ascending order as the offsets of an index
+*/
+func (b *Bitmap) Offsets() []int {
+	offsets := make([]int, 0, b.Cardinality())
+	for _, v := range b.ToArray() {
+		offsets = append(offsets, int(v))
+	}
+	return offsets
+}
+
+/*
+And returns the intersection of a and b
+*/
+func And(a, b *Bitmap) *Bitmap {
+	r := New()
+	for i, j := 0, 0; i < len(a.keys) && j < len(b.keys); {
+		switch {
+		case a.keys[i] < b.keys[j]:
+			i++
+		case a.keys[i] > b.keys[j]:
+			j++
+		default:
+			r.appendContainer(a.keys[i], combine(a.containers[i], b.containers[j], func(x, y uint64) uint64 { return x & y }))
+			i, j = i+1, j+1
+		}
+	}
+	return r
+}
+
+/*
+Or returns the union of a and b
+*/
+func Or(a, b *Bitmap) *Bitmap {
+	r := New()
+	i, j := 0, 0
+	for i < len(a.keys) || j < len(b.keys) {
+		switch {
+		case j == len(b.keys) || (i < len(a.keys) && a.keys[i] < b.keys[j]):
+			r.appendContainer(a.keys[i], a.containers[i].clone())
+			i++
+		case i == len(a.keys) || a.keys[i] > b.keys[j]:
+			r.appendContainer(b.keys[j], b.containers[j].clone())
+			j++
+		default:
+			r.appendContainer(a.keys[i], combine(a.containers[i], b.containers[j], func(x, y uint64) uint64 { return x | y }))
+			i, j = i+1, j+1
+		}
+	}
+	return r
+}
+
+/*
+AndNot returns the values of a which are not in b
+*/
+func AndNot(a, b *Bitmap) *Bitmap {
+	r := New()
+	j := 0
+	for i := range a.keys {
+		for j < len(b.keys) && b.keys[j] < a.keys[i] {
+			j++
+		}
+		if j < len(b.keys) && b.keys[j] == a.keys[i] {
+			r.appendContainer(a.keys[i], combine(a.containers[i], b.containers[j], func(x, y uint64) uint64 { return x &^ y }))
+		} else {
+			r.appendContainer(a.keys[i], a.containers[i].clone())
+		}
+	}
+	return r
+}
+
+// appendContainer adds the container of the highest key so far, unless it is empty
+func (b *Bitmap) appendContainer(key uint64, c *container) {
+	if c.card > 0 {
+		b.keys = append(b.keys, key)
+		b.containers = append(b.containers, c)
+	}
+}
+
+func (c *container) add(low uint16) {
+	if c.bitset != nil {
+		if c.bitset[low/64]&(1<<(low%64)) == 0 {
+			c.bitset[low/64] |= 1 << (low % 64)
+			c.card++
+		}
+		return
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+	if i < len(c.array) && c.array[i] == low {
+		return
+	}
+	c.array = append(c.array, 0)
+	copy(c.array[i+1:], c.array[i:])
+	c.array[i] = low
+	c.card++
+	if c.card > ARRAY_MAX {
+		c.bitset, c.array = c.words(), nil
+	}
+}
+
+func (c *container) contains(low uint16) bool {
+	if c.bitset != nil {
+		return c.bitset[low/64]&(1<<(low%64)) != 0
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+	return i < len(c.array) && c.array[i] == low
+}
+
+func (c *container) values() []uint16 {
+	if c.bitset == nil {
+		return c.array
+	}
+	values := make([]uint16, 0, c.card)
+	for w, word := range c.bitset {
+		for ; word != 0; word &= word - 1 {
+			values = append(values, uint16(w*64+bits.TrailingZeros64(word)))
+		}
+	}
+	return values
+}
+
+// words returns the container as a bitset
+func (c *container) words() []uint64 {
+	if c.bitset != nil {
+		return c.bitset
+	}
+	words := make([]uint64, BITSET_LEN)
+	for _, low := range c.array {
+		words[low/64] |= 1 << (low % 64)
+	}
+	return words
+}
+
+func (c *container) clone() *container {
+	return &container{
+		array:  append([]uint16(nil), c.array...),
+		bitset: append([]uint64(nil), c.bitset...),
+		card:   c.card,
+	}
+}
+
+// combine applies op word by word, the result is an array again if it is sparse enough
+func combine(a, b *container, op func(x, y uint64) uint64) *container {
+	if a.bitset == nil && b.bitset == nil {
+		return mergeArrays(a.array, b.array, op)
+	}
+	wa, wb := a.words(), b.words()
+	r := &container{bitset: make([]uint64, BITSET_LEN)}
+	for i := range r.bitset {
+		r.bitset[i] = op(wa[i], wb[i])
+		r.card += bits.OnesCount64(r.bitset[i])
+	}
+	if r.card <= ARRAY_MAX {
+		r.array, r.bitset = r.values(), nil
+	}
+	return r
+}
+
+// mergeArrays applies op to two sorted arrays, keeping a value according to op's truth table
+func mergeArrays(a, b []uint16, op func(x, y uint64) uint64) *container {
+	onlyA, onlyB, both := op(1, 0)&1 == 1, op(0, 1)&1 == 1, op(1, 1)&1 == 1
+	r := &container{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			if onlyA {
+				r.add(a[i])
+			}
+			i++
+		case i == len(a) || a[i] > b[j]:
+			if onlyB {
+				r.add(b[j])
+			}
+			j++
+		default:
+			if both {
+				r.add(a[i])
+			}
+			i, j = i+1, j+1
+		}
+	}
+	return r
+}
@@ -0,0 +1,91 @@
+package bitmap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdd(t *testing.T) {
+	// given
+	b := New()
+
+	// when
+	for _, v := range []uint64{70000, 3, 1 << 40, 3, 65536, 2} {
+		b.Add(v)
+	}
+
+	// then
+	assert.Equal(t, 5, b.Cardinality())
+	assert.Equal(t, []uint64{2, 3, 65536, 70000, 1 << 40}, b.ToArray())
+	assert.True(t, b.Contains(70000))
+	assert.False(t, b.Contains(70001))
+	assert.False(t, b.Contains(1<<41))
+}
+
+func TestAdd_DenseContainer(t *testing.T) {
+	// given
+	b := New()
+
+	// when more values than ARRAY_MAX share the same container
+	for v := uint64(0); v <= 2*ARRAY_MAX; v += 2 {
+		b.Add(v)
+		b.Add(v)
+	}
+
+	// then the container is a bitset
+	assert.NotNil(t, b.containers[0].bitset)
+	assert.Equal(t, ARRAY_MAX+1, b.Cardinality())
+	assert.True(t, b.Contains(2*ARRAY_MAX))
+	assert.False(t, b.Contains(3))
+	assert.Equal(t, uint64(8), b.ToArray()[4])
+}
+
+func TestSetOperations(t *testing.T) {
+	// given
+	a := FromOffsets([]int{1, 2, 3, 100000, 200000})
+	b := FromOffsets([]int{2, 3, 4, 200000, 300000})
+
+	// then
+	assert.Equal(t, []int{2, 3, 200000}, And(a, b).Offsets())
+	assert.Equal(t, []int{1, 2, 3, 4, 100000, 200000, 300000}, Or(a, b).Offsets())
+	assert.Equal(t, []int{1, 100000}, AndNot(a, b).Offsets())
+	assert.Equal(t, []int{4, 300000}, AndNot(b, a).Offsets())
+	assert.Equal(t, 0, And(a, New()).Cardinality())
+	assert.Equal(t, a.Offsets(), Or(New(), a).Offsets())
+}
+
+func TestSetOperations_DenseContainers(t *testing.T) {
+	// given the even and the multiples of 3 values of a container
+	even, three := New(), New()
+	for v := uint64(0); v < 1<<16; v++ {
+		if v%2 == 0 {
+			even.Add(v)
+		}
+		if v%3 == 0 {
+			three.Add(v)
+		}
+	}
+
+	// when
+	and, or, andNot := And(even, three), Or(even, three), AndNot(even, three)
+
+	// then
+	assert.Equal(t, 10923, and.Cardinality())
+	assert.True(t, and.Contains(6))
+	assert.False(t, and.Contains(4))
+	assert.Equal(t, 43691, or.Cardinality())
+	assert.Equal(t, 21845, andNot.Cardinality())
+	assert.True(t, andNot.Contains(4))
+	assert.False(t, andNot.Contains(6))
+}
+
+func ExampleAnd() {
+	women := FromOffsets([]int{1, 2, 5, 6})
+	aged23 := FromOffsets([]int{0, 2, 6})
+
+	fmt.Println(And(women, aged23).Offsets())
+	// Output:
+	// [2 6]
+}
@@ -0,0 +1,241 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/estimate/linear"
+	"github.com/BenJoyenConseil/rmi/index"
+)
+
+const (
+	MANIFEST    = "manifest.json"
+	INDEX_EXT   = ".rmi"
+	KEY_FLOAT64 = "float64" // the keys are parsed as float64 whatever the type of the column
+)
+
+/*
+Model describes the estimator of an index and its error bounds
+*/
+type Model struct {
+	Type        string  `json:"type"`
+	Intercept   float64 `json:"intercept"`
+	Slope       float64 `json:"slope"`
+	MinErrBound int     `json:"min_err_bound"`
+	MaxErrBound int     `json:"max_err_bound"`
+}
+
+/*
+Entry describes an index of the catalog, it is named after the table and the indexed column
+*/
+type Entry struct {
+	Name    string    `json:"name"`
+	Table   string    `json:"table"`
+	Column  string    `json:"column"`
+	Source  string    `json:"source"` // absolute path of the CSV
+	File    string    `json:"file"`   // store file, relative to the catalog directory
+	KeyType string    `json:"key_type"`
+	Layout  string    `json:"layout"`
+	Model   Model     `json:"model"`
+	Rows    int       `json:"rows"`
+	Built   time.Time `json:"built"`
+}
+
+/*
+Catalog is a directory holding many indexes and the manifest describing them
+*/
+type Catalog struct {
+	Dir     string
+	Entries []Entry
+}
+
+/*
+Open reads the manifest of the catalog dir, the catalog is empty when there is no manifest yet
+*/
+func Open(dir string) (*Catalog, error) {
+	c := &Catalog{Dir: dir}
+	b, err := ioutil.ReadFile(filepath.Join(dir, MANIFEST))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.Entries); err != nil {
+		return nil, fmt.Errorf("%s: %s", filepath.Join(dir, MANIFEST), err)
+	}
+	return c, nil
+}
+
+/*
+TableName returns the name of the table of a CSV file : its base name without extension
+*/
+func TableName(csv string) string {
+	base := filepath.Base(csv)
+	return strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+/*
+EntryName returns the name of the index over the column of the table
+*/
+func EntryName(table, column string) string {
+	return table + "." + strings.ToLower(column)
+}
+
+/*
+CheckName returns an error when the name can't name a file of the catalog directory:
+it is empty, holds a path separator or ".."
+*/
+func CheckName(name string) error {
+	if name == "" || name == "." || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`+string(filepath.Separator)) {
+		return fmt.Errorf("%q can't name an index, it must not be empty nor hold a path separator or \"..\"", name)
+	}
+	return nil
+}
+
+/*
+Describe returns the Entry of the index named name, Built is left to the caller
+*/
+func Describe(name string, idx *index.DiskIndex) (Entry, error) {
+	lr, ok := idx.M.(*linear.RegressionModel)
+	if !ok {
+		return Entry{}, fmt.Errorf("the model %T can't be described", idx.M)
+	}
+	return Entry{
+		Name:    name,
+		Table:   TableName(idx.Source.Path),
+		Column:  strings.ToLower(idx.Source.Column),
+		Source:  idx.Source.Path,
+		File:    name + INDEX_EXT,
+		KeyType: KEY_FLOAT64,
+		Layout:  idx.S.Layout().String(),
+		Model: Model{
+			Type:        "linear",
+			Intercept:   lr.Intercept,
+			Slope:       lr.Slope,
+			MinErrBound: idx.MinErrBound,
+			MaxErrBound: idx.MaxErrBound,
+		},
+		Rows: idx.Count(),
+	}, nil
+}
+
+/*
+Path returns the path of the store file of e
+*/
+func (c *Catalog) Path(e Entry) string {
+	return filepath.Join(c.Dir, e.File)
+}
+
+/*
+Get returns the entry named name
+*/
+func (c *Catalog) Get(name string) (Entry, bool) {
+	for _, e := range c.Entries {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+/*
+Find returns the entry indexing the column of the table. The table can be omitted
+as long as a single table has an index over the column
+*/
+func (c *Catalog) Find(table, column string) (Entry, error) {
+	column = strings.ToLower(column)
+	var found []Entry
+	for _, e := range c.Entries {
+		if e.Column == column && (table == "" || e.Table == strings.ToLower(table)) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		if table == "" {
+			return Entry{}, fmt.Errorf("no index over the column %q in %s", column, c.Dir)
+		}
+		return Entry{}, fmt.Errorf("no index over the column %q of the table %q in %s", column, table, c.Dir)
+	case 1:
+		return found[0], nil
+	}
+	return Entry{}, fmt.Errorf("the column %q is indexed in several tables, choose one of them", column)
+}
+
+/*
+Add records e in the manifest, replacing the entry of the same name
+*/
+func (c *Catalog) Add(e Entry) error {
+	if err := CheckName(e.Name); err != nil {
+		return err
+	}
+	for i := range c.Entries {
+		if c.Entries[i].Name == e.Name {
+			c.Entries[i] = e
+			return c.Save()
+		}
+	}
+	c.Entries = append(c.Entries, e)
+	sort.Slice(c.Entries, func(i, j int) bool { return c.Entries[i].Name < c.Entries[j].Name })
+	return c.Save()
+}
+
+/*
+Drop deletes the store file of the entry named name and removes it from the manifest
+*/
+func (c *Catalog) Drop(name string) error {
+	for i, e := range c.Entries {
+		if e.Name != name {
+			continue
+		}
+		if err := os.Remove(c.Path(e)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		c.Entries = append(c.Entries[:i], c.Entries[i+1:]...)
+		return c.Save()
+	}
+	return fmt.Errorf("no index named %q in %s", name, c.Dir)
+}
+
+/*
+Save writes the manifest, through a temporary file renamed over the previous one
+*/
+func (c *Catalog) Save() error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	entries := c.Entries
+	if entries == nil {
+		entries = []Entry{}
+	}
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.Dir, MANIFEST+".*")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.Dir, MANIFEST))
+}
@@ -0,0 +1,116 @@
+package catalog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableName(t *testing.T) {
+	assert.Equal(t, "people", TableName("/data/People.csv"))
+	assert.Equal(t, "titanic", TableName("titanic"))
+	assert.Equal(t, "people.age", EntryName("people", "Age"))
+}
+
+func TestCheckName(t *testing.T) {
+	for _, name := range []string{"people.age", "orders_2020.customer-id"} {
+		assert.NoError(t, CheckName(name), name)
+	}
+	for _, name := range []string{"", ".", "..", "../x", "a/b", `a\b`, "a..b"} {
+		assert.Error(t, CheckName(name), name)
+	}
+
+	// given
+	c, _ := Open(t.TempDir())
+	// when
+	err := c.Add(Entry{Name: "../people.age", File: "../people.age.rmi"})
+	// then
+	assert.Error(t, err)
+	assert.Empty(t, c.Entries)
+}
+
+func TestOpen_WithoutManifest(t *testing.T) {
+	// when
+	c, err := Open(filepath.Join(t.TempDir(), "missing"))
+
+	// then
+	assert.NoError(t, err)
+	assert.Empty(t, c.Entries)
+}
+
+func TestDescribe(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	idx := index.New([]float64{90, 23, 3})
+	f, _ := os.Create(filepath.Join(dir, "people.age.rmi"))
+	defer f.Close()
+	pointers := []table.Pointer{0, 1, 2}
+	index.FlushPointers(idx, store.NewFileBackend(f), store.Options{Layout: store.COLUMNAR}, index.Source{Path: "/data/people.csv", Column: "Age"}, pointers)
+	disk, _ := index.OpenDisk(store.NewFileBackend(f))
+
+	// when
+	e, err := Describe("people.age", disk)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "people", e.Table)
+	assert.Equal(t, "age", e.Column)
+	assert.Equal(t, "/data/people.csv", e.Source)
+	assert.Equal(t, "people.age.rmi", e.File)
+	assert.Equal(t, KEY_FLOAT64, e.KeyType)
+	assert.Equal(t, "columnar", e.Layout)
+	assert.Equal(t, "linear", e.Model.Type)
+	assert.Equal(t, 3, e.Rows)
+}
+
+func TestAddFindDrop(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	c, _ := Open(dir)
+	built := time.Date(2020, 11, 15, 20, 29, 56, 0, time.UTC)
+	entries := []Entry{
+		{Name: "people.age", Table: "people", Column: "age", File: "people.age.rmi", Rows: 7, Built: built},
+		{Name: "titanic.age", Table: "titanic", Column: "age", File: "titanic.age.rmi", Rows: 891, Built: built},
+		{Name: "titanic.pclass", Table: "titanic", Column: "pclass", File: "titanic.pclass.rmi", Rows: 891, Built: built},
+	}
+	for _, e := range entries {
+		ioutil.WriteFile(filepath.Join(dir, e.File), []byte{}, 0644)
+		assert.NoError(t, c.Add(e))
+	}
+
+	// when
+	reopened, err := Open(dir)
+	pclass, errPclass := reopened.Find("", "PClass")
+	age, errAge := reopened.Find("titanic", "age")
+	_, errAmbiguous := reopened.Find("", "age")
+	_, errMissing := reopened.Find("people", "pclass")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, entries, reopened.Entries)
+	assert.NoError(t, errPclass)
+	assert.Equal(t, "titanic.pclass", pclass.Name)
+	assert.NoError(t, errAge)
+	assert.Equal(t, "titanic.age", age.Name)
+	assert.Error(t, errAmbiguous)
+	assert.Error(t, errMissing)
+
+	// when
+	err = reopened.Drop("titanic.age")
+	reopened, _ = Open(dir)
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, reopened.Entries, 2)
+	_, found := reopened.Get("titanic.age")
+	assert.False(t, found)
+	assert.NoFileExists(t, filepath.Join(dir, "titanic.age.rmi"))
+	assert.Error(t, reopened.Drop("titanic.age"))
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
+)
+
+const (
+	KEYS_BATCH = 1024 // keys read before they are looked up
+)
+
+/*
+searchKeys looks up the keys read from path by batches of KEYS_BATCH, and writes the matches of each key
+tagged with it, in the order of the input. A key matching no row is a query without match, not an error
+*/
+func searchKeys(path, column string) error {
+	indexPath, err := resolveIndex(*selectIndexFile, *selectCatalog, *selectTable, *selectColumn)
+	if err != nil {
+		return err
+	}
+	storeFile, err := os.Open(indexPath)
+	if err != nil {
+		return err
+	}
+	defer storeFile.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(storeFile))
+	if err != nil {
+		return err
+	}
+	if err := checkSource(indexPath, idx.Source, *selectStale); err != nil {
+		return err
+	}
+	src, err := openSource(idx)
+	if err != nil {
+		return err
+	}
+	if src != nil {
+		defer src.Close()
+	}
+
+	out := newResultWriter(stdout, stderr, *selectFormat)
+	err = keyBatches(path, column, func(keys []float64) error {
+		return searchBatch(idx, src, out, keys)
+	})
+	if err != nil {
+		return err
+	}
+	return out.close()
+}
+
+/*
+searchBatch looks up the distinct keys of the batch in ascending order, so that the pages of the store
+are read forward, then writes their matches in the order of the batch. With --lines-only, the source is scanned
+once for the whole batch
+*/
+func searchBatch(idx *index.DiskIndex, src *os.File, out *resultWriter, keys []float64) error {
+	sorted := append([]float64(nil), keys...)
+	sort.Float64s(sorted)
+	postings := make(map[float64][]int, len(keys))
+	spent := make(map[float64]time.Duration, len(keys))
+	for i, k := range sorted {
+		if i > 0 && k == sorted[i-1] {
+			continue
+		}
+		start := time.Now()
+		if b, err := idx.Postings(k); err == nil {
+			postings[k] = b.Offsets()
+		}
+		spent[k] = time.Since(start)
+	}
+	// the line numbers of the whole batch are resolved in a single scan of the source
+	var pointers []int
+	for _, p := range postings {
+		pointers = append(pointers, p...)
+	}
+	lines, err := matchLines(idx.Source, src, pointers)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		key := k
+		out.beginAfter(keyQuery(idx.Source, key), spent[key])
+		if err := writeRows(out, src, &key, postings[key], lines); err != nil {
+			return err
+		}
+		out.end()
+	}
+	return nil
+}
+
+/*
+keyBatches reads the keys of path, - for the standard input, and calls fn with KEYS_BATCH of them at a time.
+The keys are one per line, or the values of the column of a CSV file with a header. Blank ones are skipped
+*/
+func keyBatches(path, column string, fn func(keys []float64) error) error {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	next := lineKeys(r)
+	if column != "" {
+		format := table.DefaultFormat()
+		s := format.NewScanner(r)
+		field, _, err := format.Locate(s, column)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		next = columnKeys(s, field)
+	}
+
+	batch := make([]float64, 0, KEYS_BATCH)
+	for {
+		text, line, ok, err := next()
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		if ok && strings.TrimSpace(text) != "" {
+			k, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+			if err != nil {
+				return fmt.Errorf("%s: line %d: %q is not a number", path, line, text)
+			}
+			batch = append(batch, k)
+		}
+		if len(batch) == KEYS_BATCH || (!ok && len(batch) > 0) {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		if !ok {
+			return nil
+		}
+	}
+}
+
+// lineKeys returns the lines of r one by one, ok is false at the end of r
+func lineKeys(r io.Reader) func() (text string, line int, ok bool, err error) {
+	s := bufio.NewScanner(r)
+	line := 0
+	return func() (string, int, bool, error) {
+		if !s.Scan() {
+			return "", line, false, s.Err()
+		}
+		line++
+		return s.Text(), line, true, nil
+	}
+}
+
+// columnKeys returns the field of the rows of s one by one, ok is false at the end of s
+func columnKeys(s *table.Scanner, field int) func() (text string, line int, ok bool, err error) {
+	return func() (string, int, bool, error) {
+		if !s.Scan() {
+			return "", 0, false, s.Err()
+		}
+		row := s.Row()
+		if field >= len(row.Fields) {
+			return "", row.Line, false, fmt.Errorf("line %d: the row has only %d fields", row.Line, len(row.Fields))
+		}
+		return row.Fields[field], row.Line, true, nil
+	}
+}
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/search"
+	"github.com/BenJoyenConseil/rmi/table"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+/*
+strategy builds a structure over the keys and returns the lookup function searching it
+*/
+type strategy struct {
+	Name  string
+	Build func(keys []float64) func(key float64) ([]int, error)
+}
+
+var strategies = []strategy{
+	{"learned", func(keys []float64) func(float64) ([]int, error) {
+		return index.New(keys).Lookup
+	}},
+	{"binary-search", func(keys []float64) func(float64) ([]int, error) {
+		st := search.NewSortedTable(keys)
+		return func(key float64) ([]int, error) { return search.BinarySearchLookup(key, st) }
+	}},
+	{"full-scan", func(keys []float64) func(float64) ([]int, error) {
+		st := search.NewSortedTable(keys)
+		return func(key float64) ([]int, error) { return search.FullScanLookup(key, st) }
+	}},
+	{"tree", func(keys []float64) func(float64) ([]int, error) {
+		return search.NewTree(search.NewSortedTable(keys)).Lookup
+	}},
+}
+
+/*
+benchResult measures a strategy replaying the queries, the durations are in nanoseconds
+*/
+type benchResult struct {
+	Strategy   string  `json:"strategy"`
+	Build      int64   `json:"build_ns"`
+	Memory     int64   `json:"memory_bytes"` // the heap held by the structure once built
+	Queries    int     `json:"queries"`
+	Found      int     `json:"found"`
+	P50        int64   `json:"p50_ns"`
+	P90        int64   `json:"p90_ns"`
+	P99        int64   `json:"p99_ns"`
+	Max        int64   `json:"max_ns"`
+	Throughput float64 `json:"queries_per_second"`
+}
+
+func benchLookups(c *kingpin.ParseContext) error {
+	if *benchQueries == "" && *benchRandom < 1 {
+		return fmt.Errorf("--random must be at least 1, got %d", *benchRandom)
+	}
+	format := table.DefaultFormat()
+	format.BadValues = table.SKIP
+	column, _, err := extractColumn(*benchCSV, *benchColumn, &format, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	if len(column.Keys) == 0 {
+		return fmt.Errorf("no row of %s holds a number in the column %s", *benchCSV, *benchColumn)
+	}
+	var queries []float64
+	if *benchQueries != "" {
+		if queries, err = readKeys(*benchQueries); err != nil {
+			return err
+		}
+	} else {
+		queries = make([]float64, *benchRandom)
+		r := rand.New(rand.NewSource(1))
+		for i := range queries {
+			queries[i] = column.Keys[r.Intn(len(column.Keys))]
+		}
+	}
+	if len(queries) == 0 {
+		return fmt.Errorf("no query to replay")
+	}
+
+	results := make([]benchResult, len(strategies))
+	var expected []int
+	for i, s := range strategies {
+		var counts []int
+		results[i], counts = runStrategy(s, column.Keys, queries)
+		if i == 0 {
+			expected = counts
+		} else if err := checkCounts(strategies[0].Name, s.Name, queries, expected, counts); err != nil {
+			return err
+		}
+	}
+	if *benchJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	fmt.Fprintf(stdout, "%d keys of %s, %d queries\n", len(column.Keys), *benchCSV, len(queries))
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STRATEGY\tBUILD\tMEMORY\tFOUND\tP50\tP90\tP99\tMAX\tQUERIES/S")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%.1f KiB\t%d\t%s\t%s\t%s\t%s\t%.0f\n", r.Strategy, time.Duration(r.Build), float64(r.Memory)/1024, r.Found,
+			time.Duration(r.P50), time.Duration(r.P90), time.Duration(r.P99), time.Duration(r.Max), r.Throughput)
+	}
+	return w.Flush()
+}
+
+// runStrategy builds the structure of s over a copy of the keys, then times each query.
+// It also returns the number of offsets found by each query
+func runStrategy(s strategy, keys, queries []float64) (benchResult, []int) {
+	r := benchResult{Strategy: s.Name, Queries: len(queries)}
+	before := heapAlloc()
+	start := time.Now()
+	lookup := s.Build(append([]float64(nil), keys...))
+	r.Build = int64(time.Since(start))
+	r.Memory = int64(heapAlloc()) - int64(before)
+
+	latencies, counts := make([]int64, len(queries)), make([]int, len(queries))
+	total := time.Now()
+	for i, q := range queries {
+		start := time.Now()
+		offsets, err := lookup(q)
+		latencies[i] = int64(time.Since(start))
+		if err == nil {
+			r.Found++
+		}
+		counts[i] = len(offsets)
+	}
+	r.Throughput = float64(len(queries)) / time.Since(total).Seconds()
+	runtime.KeepAlive(lookup)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) int64 { return latencies[int(p*float64(len(latencies)-1))] }
+	r.P50, r.P90, r.P99, r.Max = percentile(.5), percentile(.9), percentile(.99), latencies[len(latencies)-1]
+	return r, counts
+}
+
+// checkCounts returns an error when the strategies named reference and name don't find as many offsets for a query
+func checkCounts(reference, name string, queries []float64, expected, counts []int) error {
+	for i, q := range queries {
+		if counts[i] != expected[i] {
+			return fmt.Errorf("%s finds %d offsets for the key %g where %s finds %d", name, counts[i], q, reference, expected[i])
+		}
+	}
+	return nil
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+/*
+readKeys parses a key per line of the file, - for the standard input. The blank lines are skipped
+*/
+func readKeys(path string) ([]float64, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	keys := []float64{}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+		k, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %s", path, line, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, s.Err()
+}
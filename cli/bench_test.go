@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStrategy_Duplicates(t *testing.T) {
+	// given keys duplicated far beyond the error window of the model
+	keys := make([]float64, 1000)
+	for i := range keys {
+		keys[i] = float64(i % 3)
+	}
+	queries := []float64{0, 1, 2, 3}
+
+	// when
+	var expected []int
+	for i, s := range strategies {
+		r, counts := runStrategy(s, keys, queries)
+
+		// then every strategy finds all the duplicates
+		assert.Equal(t, 3, r.Found, s.Name)
+		if i == 0 {
+			expected = counts
+			assert.Equal(t, []int{334, 333, 333, 0}, counts)
+		} else {
+			assert.NoError(t, checkCounts(strategies[0].Name, s.Name, queries, expected, counts))
+		}
+	}
+}
+
+func TestCheckCounts(t *testing.T) {
+	// when a strategy misses an offset
+	err := checkCounts("learned", "tree", []float64{1, 2}, []int{1, 3}, []int{1, 2})
+
+	// then
+	assert.EqualError(t, err, "tree finds 2 offsets for the key 2 where learned finds 3")
+}
+
+func TestBench_Random(t *testing.T) {
+	// given
+	csv := filepath.Join(t.TempDir(), "people.csv")
+	ioutil.WriteFile(csv, []byte(PEOPLE), 0644)
+
+	for _, random := range []string{"0", "-1"} {
+		// when
+		_, _, err := run("bench", "-f", csv, "-c", "age", "--random="+random)
+
+		// then
+		assert.EqualError(t, err, "--random must be at least 1, got "+random)
+	}
+}
+
+func TestBench_Queries(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	csv, queries := filepath.Join(dir, "people.csv"), filepath.Join(dir, "queries.txt")
+	ioutil.WriteFile(csv, []byte(PEOPLE), 0644)
+	ioutil.WriteFile(queries, []byte("23\n24\n90\n"), 0644)
+
+	// when
+	out, _, err := run("bench", "-f", csv, "-c", "age", "--queries", queries, "--json")
+
+	// then every strategy finds the same keys
+	assert.NoError(t, err)
+	var results []benchResult
+	assert.NoError(t, json.Unmarshal([]byte(out), &results))
+	assert.Len(t, results, len(strategies))
+	for i, r := range results {
+		assert.Equal(t, strategies[i].Name, r.Strategy)
+		assert.Equal(t, 3, r.Queries)
+		assert.Equal(t, 2, r.Found)
+	}
+}
@@ -1,47 +1,169 @@
 package cli
 
 import (
-	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/BenJoyenConseil/rmi/catalog"
 	"github.com/BenJoyenConseil/rmi/index"
 	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 const (
-	IndexFileName        = "data/index.rmi"
+	DefaultCatalog       = "data"
 	DefaultPlotImgFormat = "svg"
 	DefaultPlotFolder    = "assets"
+
+	STALE_IGNORE = "ignore"
+	STALE_WARN   = "warn"
+	STALE_REFUSE = "refuse"
 )
 
 var (
+	// the commands print their results to stdout and their diagnostics to stderr, the tests replace them
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+
 	app = kingpin.New("rmi", "learns from data to index efficiently your CSV")
 
 	create        = app.Command("create", "build an index structure that learn distribution over values of a column")
 	fileToIndex   = create.Flag("csv", "The CSV file you want to index").Short('f').Required().String()
-	columnToIndex = create.Flag("column", "The column you want to index").Short('c').Required().String()
+	columnToIndex = create.Flag("column", "The column you want to index").Short('c').String()
+	columnNumber  = create.Flag("column-index", "the position of the column you want to index, starting at 0, instead of its name").Default("-1").Int()
+	delimiter     = create.Flag("delimiter", "the character separating the fields, \\t for a tab").Short('d').Default(",").String()
+	quote         = create.Flag("quote", "the character enclosing the fields holding delimiters or line breaks").Default(`"`).String()
+	noHeader      = create.Flag("no-header", "the first row is a row of values, not the names of the columns").Bool()
+	badValues     = create.Flag("bad-values", "what to do with the rows whose value is not a number: skip them, fail, or index them under --default").Default(table.SKIP.String()).Enum(table.SKIP.String(), table.FAIL.String(), table.DEFAULT.String())
+	defaultKey    = create.Flag("default", "the key of the rows whose value is not a number, with --bad-values default").Default("0").Float64()
+	createOutput  = create.Flag("output", "write the index file at this path, outside of the catalog").Short('o').String()
+	createCatalog = create.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	createName    = create.Flag("name", "the name of the index, <table>.<column> by default").String()
+	pageSize      = create.Flag("page-size", "align the records on pages of this size in bytes, 0 to disable").Default(strconv.FormatInt(store.PAGE_SIZE, 10)).Int64()
+	storeLayout   = create.Flag("layout", "how the records are written: interleaved key/value pairs, columnar key and value sections or compressed blocks").Default(store.INTERLEAVED.String()).Enum(store.INTERLEAVED.String(), store.COLUMNAR.String(), store.COMPRESSED.String())
 	createAction  = create.Action(createIndex)
 
 	count          = app.Command("count", "read the first Byte where the count is stored and print it")
-	countIndexFile = count.Flag("index", "the index file").Short('i').Default(IndexFileName).ExistingFile()
+	countIndexFile = count.Flag("index", "the index file, instead of the index of the catalog").Short('i').ExistingFile()
+	countCatalog   = count.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	countTable     = count.Flag("table", "the table of the index").Short('t').String()
+	countColumn    = count.Flag("column", "the column of the index").Short('c').String()
+	countStale     = count.Flag("stale", "what to do when the source CSV has changed since the index was built").Default(STALE_WARN).Enum(STALE_IGNORE, STALE_WARN, STALE_REFUSE)
+	countFormat    = count.Flag("format", "print the count as text, json, ndjson or csv").Default(FORMAT_TEXT).Enum(outputFormats...)
 	countAction    = count.Action(countElements)
 
 	select_         = app.Command("search", "query the index file found in the targeted directory")
-	selectIndexFile = select_.Flag("index", "the index file").Short('i').Default(IndexFileName).ExistingFile()
-	searchedValue   = select_.Arg("key", "designates the key used to find the corresponding lines").String()
+	selectIndexFile = select_.Flag("index", "the index file, instead of the index of the catalog").Short('i').ExistingFile()
+	selectCatalog   = select_.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	selectTable     = select_.Flag("table", "the table of the index, or of the column=value predicates").Short('t').String()
+	selectColumn    = select_.Flag("column", "the column of the index").Short('c').String()
+	selectAny       = select_.Flag("any", "match the rows satisfying any of the predicates instead of all of them").Bool()
+	selectStale     = select_.Flag("stale", "what to do when the source CSV has changed since the index was built").Default(STALE_WARN).Enum(STALE_IGNORE, STALE_WARN, STALE_REFUSE)
+	selectLinesOnly = select_.Flag("lines-only", "print the line numbers of the matching rows in the CSV instead of the rows").Bool()
+	selectFormat    = select_.Flag("format", "print the matching rows as text, json, ndjson or csv, with their key, offset and timing").Default(FORMAT_TEXT).Enum(outputFormats...)
+	selectKeysFrom  = select_.Flag("keys-from", "look up the keys of this file, one per line, - for the standard input").String()
+	selectKeysCol   = select_.Flag("keys-column", "with --keys-from, read the keys from this column of a CSV file with a header").String()
+	searchedValues  = select_.Arg("key", "designates the key used to find the corresponding lines, or column=value / column!=value predicates").Strings()
 	selectAction    = select_.Action(selectWhere)
 
+	range_           = app.Command("range", "print the rows whose key is between --from and --to, in key order")
+	rangeIndexFile   = range_.Flag("index", "the index file, instead of the index of the catalog").Short('i').ExistingFile()
+	rangeCatalog     = range_.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	rangeTable       = range_.Flag("table", "the table of the index").Short('t').String()
+	rangeColumn      = range_.Flag("column", "the column of the index").Short('c').String()
+	rangeFrom        = range_.Flag("from", "the lowest key").Required().Float64()
+	rangeTo          = range_.Flag("to", "the highest key").Required().Float64()
+	rangeIncludeFrom = range_.Flag("inclusive-from", "include the rows holding the --from key, --no-inclusive-from to exclude them").Default("true").Bool()
+	rangeExcludeTo   = range_.Flag("exclusive-to", "exclude the rows holding the --to key").Bool()
+	rangeLimit       = range_.Flag("limit", "print at most this number of rows, 0 for all of them").Int()
+	rangeStale       = range_.Flag("stale", "what to do when the source CSV has changed since the index was built").Default(STALE_WARN).Enum(STALE_IGNORE, STALE_WARN, STALE_REFUSE)
+	rangeFormat      = range_.Flag("format", "print the rows as text, json, ndjson or csv, with their key, offset and timing").Default(FORMAT_TEXT).Enum(outputFormats...)
+	rangeAction      = range_.Action(rangeRows)
+
+	list        = app.Command("list", "list the indexes of the catalog")
+	listCatalog = list.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	listAction  = list.Action(listIndexes)
+
+	refresh          = app.Command("refresh", "index the rows appended to the CSV since the index was built")
+	refreshIndexFile = refresh.Flag("index", "the index file, instead of the index of the catalog").Short('i').ExistingFile()
+	refreshCatalog   = refresh.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	refreshTable     = refresh.Flag("table", "the table of the index").Short('t').String()
+	refreshColumn    = refresh.Flag("column", "the column of the index").Short('c').String()
+	refreshPageSize  = refresh.Flag("page-size", "align the records on pages of this size in bytes when they are rewritten, 0 to disable").Default(strconv.FormatInt(store.PAGE_SIZE, 10)).Int64()
+	refreshAction    = refresh.Action(refreshIndex)
+
+	status        = app.Command("status", "report the indexes of the catalog whose source CSV has changed since they were built")
+	statusCatalog = status.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	statusAction  = status.Action(statusIndexes)
+
+	drop        = app.Command("drop", "delete an index of the catalog")
+	dropCatalog = drop.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	dropTable   = drop.Flag("table", "the table of the index").Short('t').String()
+	dropColumn  = drop.Flag("column", "the column of the index").Short('c').String()
+	dropName    = drop.Arg("name", "the name of the index, instead of --table and --column").String()
+	dropAction  = drop.Action(dropIndex)
+
+	verify          = app.Command("verify", "check the header, the checksums, the order and the error bounds of an index")
+	verifyIndexFile = verify.Flag("index", "the index file, instead of the index of the catalog").Short('i').ExistingFile()
+	verifyCatalog   = verify.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	verifyTable     = verify.Flag("table", "the table of the index").Short('t').String()
+	verifyColumn    = verify.Flag("column", "the column of the index").Short('c').String()
+	verifyCSV       = verify.Flag("csv", "also parse the source CSV and compare the lookups of every key with a full scan").Bool()
+	verifyAction    = verify.Action(verifyIndex)
+
+	inspect          = app.Command("inspect", "print the internals of an index: format, model, error bounds and sections")
+	inspectIndexFile = inspect.Flag("index", "the index file, instead of the index of the catalog").Short('i').ExistingFile()
+	inspectCatalog   = inspect.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	inspectTable     = inspect.Flag("table", "the table of the index").Short('t').String()
+	inspectColumn    = inspect.Flag("column", "the column of the index").Short('c').String()
+	inspectJSON      = inspect.Flag("json", "print the report as a JSON object").Bool()
+	inspectAction    = inspect.Action(inspectIndex)
+
+	bench        = app.Command("bench", "compare the lookups of the learned index with a binary search, a full scan and a B+tree over a column")
+	benchCSV     = bench.Flag("csv", "the CSV file holding the column").Short('f').Required().ExistingFile()
+	benchColumn  = bench.Flag("column", "the column to index").Short('c').Required().String()
+	benchQueries = bench.Flag("queries", "a file holding a key per line to look up, - for the standard input, instead of random keys of the column").String()
+	benchRandom  = bench.Flag("random", "the number of random keys of the column looked up without --queries").Default("1000").Int()
+	benchJSON    = bench.Flag("json", "print the results as a JSON array").Bool()
+	benchAction  = bench.Action(benchLookups)
+
 	plot                 = app.Command("plot", "print a graphic representation of the index, its cdf, the approximation used")
+	plotIndexFile        = plot.Flag("index", "the index file, instead of the index of the catalog").Short('i').ExistingFile()
+	plotCatalog          = plot.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	plotTable            = plot.Flag("table", "the table of the index").String()
+	plotColumn           = plot.Flag("column", "the column of the index").Short('c').String()
 	plotDir              = plot.Flag("dir", "the Dir where to write the resulting file").Short('d').Default(DefaultPlotFolder).ExistingDir()
 	plotExt              = plot.Flag("type", "The image type : png, svg, jpg").Short('t').Default(DefaultPlotImgFormat).Enum("svg", "png", "jpg")
 	plotSmoothBoundaries = plot.Flag("smooth", "define if the boundaries are smoothed or let them raw").Default("true").Bool()
 	plotAction           = plot.Action(plotIndex)
+
+	join            = app.Command("join", "write the rows of a CSV joined with the rows of an indexed table holding their key, as CSV")
+	joinLeft        = join.Flag("left", "the CSV file whose rows are joined").Required().ExistingFile()
+	joinOn          = join.Flag("on", "the column of the left CSV holding the keys, its position starting at 0 with --left-no-header").Required().String()
+	joinDelimiter   = join.Flag("left-delimiter", "the character separating the fields of the left CSV, \\t for a tab").Default(",").String()
+	joinQuote       = join.Flag("left-quote", "the character enclosing the fields of the left CSV holding delimiters or line breaks").Default(`"`).String()
+	joinNoHeader    = join.Flag("left-no-header", "the first row of the left CSV is a row of values, not the names of the columns").Bool()
+	joinRightIndex  = join.Flag("right-index", "the index of the right table, by its name or by the name of the table").Required().String()
+	joinRightColumn = join.Flag("right-column", "the column of the index, when the right table has several indexes").String()
+	joinCatalog     = join.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	joinStale       = join.Flag("stale", "what to do when the right CSV has changed since the index was built").Default(STALE_WARN).Enum(STALE_IGNORE, STALE_WARN, STALE_REFUSE)
+	joinAction      = join.Action(joinRows)
+
+	serve        = app.Command("serve", "answer the lookups and the ranges over the indexes of the catalog as JSON over HTTP")
+	serveAddr    = serve.Flag("addr", "the address to listen on").Default(":8080").String()
+	serveCatalog = serve.Flag("catalog", "the directory holding the indexes and their manifest").Default(DefaultCatalog).String()
+	serveStale   = serve.Flag("stale", "what to do when the source CSV of an index has changed since it was built").Default(STALE_WARN).Enum(STALE_IGNORE, STALE_WARN, STALE_REFUSE)
+	serveAction  = serve.Action(serveIndexes)
 )
 
 func Parse(args []string) {
@@ -49,83 +171,661 @@ func Parse(args []string) {
 }
 
 func createIndex(c *kingpin.ParseContext) error {
-	ageColumn := extractColumn(*fileToIndex, *columnToIndex)
+	format, err := createFormat()
+	if err != nil {
+		return err
+	}
+	source, err := filepath.Abs(*fileToIndex)
+	if err != nil {
+		return err
+	}
+	// the source is recorded before it is parsed, the rows appended meanwhile are left to rmi refresh
+	src, err := index.StatSource(source, *columnToIndex)
+	if err != nil {
+		return err
+	}
+	column, name, err := extractColumn(*fileToIndex, *columnToIndex, &format, src.Size)
+	if err != nil {
+		return err
+	}
+	if len(column.Keys) == 0 {
+		return fmt.Errorf("no row of %s could be indexed, %d rejected", *fileToIndex, column.Rejected)
+	}
+	src.Column, src.Format = name, format
+	cat, err := catalog.Open(*createCatalog)
+	if err != nil {
+		return err
+	}
+	entryName := *createName
+	if entryName == "" {
+		entryName = catalog.EntryName(catalog.TableName(source), name)
+	}
+	if err := catalog.CheckName(entryName); err != nil {
+		return err
+	}
+	path := *createOutput
+	if path == "" {
+		if err := os.MkdirAll(cat.Dir, 0755); err != nil {
+			return err
+		}
+		path = filepath.Join(cat.Dir, entryName+catalog.INDEX_EXT)
+	}
+
+	// create an index over the column
+	idx := index.New(column.Keys)
+	storeFile, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer storeFile.Close()
+	layout, err := store.ParseLayout(*storeLayout)
+	if err != nil {
+		return err
+	}
+	_, err = index.FlushPointers(idx, store.NewFileBackend(storeFile), store.Options{PageSize: *pageSize, Layout: layout}, src, column.Pointers)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%d rows indexed in %s, %d rejected\n", len(column.Keys), path, column.Rejected)
+	if *createOutput != "" {
+		return nil
+	}
+
+	// record the index in the manifest of the catalog
+	disk, err := index.OpenDisk(store.NewFileBackend(storeFile))
+	if err != nil {
+		return err
+	}
+	entry, err := catalog.Describe(entryName, disk)
+	if err != nil {
+		return err
+	}
+	entry.Built = time.Now().UTC()
+	return cat.Add(entry)
+}
 
-	// create an index over the age column
-	idx := index.New(ageColumn)
-	storeFile, _ := os.OpenFile(IndexFileName, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
-	s := store.Store{storeFile}
+// createFormat returns the format of the CSV given by the flags of the create command
+func createFormat() (table.Format, error) {
+	format := table.Format{Header: !*noHeader, Field: *columnNumber, Default: *defaultKey}
+	if *columnToIndex == "" && *columnNumber < 0 {
+		return format, fmt.Errorf("designate the column by its name with --column or by its position with --column-index")
+	}
+	if math.IsNaN(*defaultKey) || math.IsInf(*defaultKey, 0) {
+		return format, fmt.Errorf("the default key must be a finite number, got %v", *defaultKey)
+	}
+	if err := setDelimiters(&format, *delimiter, *quote); err != nil {
+		return format, err
+	}
+	policy, err := table.ParseBadValues(*badValues)
+	format.BadValues = policy
+	return format, err
+}
 
-	for i := 0; i < idx.Len; i++ {
-		log.Println(idx.ST.Keys[i], uint64(idx.ST.Offsets[i]))
-		r := store.ToRecord(idx.ST.Keys[i], uint64(idx.ST.Offsets[i]))
-		s.Put(r)
+// setDelimiters sets the delimiter and the quote given by the flags to format, \t designates a tab
+func setDelimiters(format *table.Format, delimiter, quote string) error {
+	comma := delimiter
+	if comma == `\t` {
+		comma = "\t"
+	}
+	if len(comma) != 1 || len(quote) != 1 {
+		return fmt.Errorf("the delimiter and the quote must be single bytes, got %q and %q", delimiter, quote)
+	}
+	format.Comma, format.Quote = comma[0], quote[0]
+	if format.Comma == format.Quote || format.Comma == '\n' || format.Quote == '\n' {
+		return fmt.Errorf("the delimiter %q and the quote %q can't be used together", format.Comma, format.Quote)
 	}
 	return nil
 }
 
 func countElements(c *kingpin.ParseContext) error {
-	storeFile, err := os.OpenFile(IndexFileName, os.O_RDONLY, 0644)
-	s := store.Store{storeFile}
-	fmt.Println(s.RecordCount())
-	return err
+	path, err := resolveIndex(*countIndexFile, *countCatalog, *countTable, *countColumn)
+	if err != nil {
+		return err
+	}
+	storeFile, err := os.OpenFile(path, os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer storeFile.Close()
+	start := time.Now()
+	if idx, err := index.OpenDisk(store.NewFileBackend(storeFile)); err == nil {
+		if err := checkSource(path, idx.Source, *countStale); err != nil {
+			return err
+		}
+		return printCount(stdout, *countFormat, countResult{Index: path, Count: int64(idx.Count()), Elapsed: int64(time.Since(start))})
+	}
+	s := store.Store{Backend: store.NewFileBackend(storeFile)}
+	return printCount(stdout, *countFormat, countResult{Index: path, Count: s.RecordCount(), Elapsed: int64(time.Since(start))})
 }
 
-func selectWhere(c *kingpin.ParseContext) error {
-	/*
-		const FIRST_LINE_OF_DATA int = 2
-		search, _ := strconv.ParseFloat(os.Args[1], 64)
-		log.Println("max error is :", idx.MaxErrBound, "; min error is", idx.MinErrBound)
+func listIndexes(c *kingpin.ParseContext) error {
+	cat, err := catalog.Open(*listCatalog)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTABLE\tCOLUMN\tKEY\tROWS\tLAYOUT\tBUILT\tSOURCE")
+	for _, e := range cat.Entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", e.Name, e.Table, e.Column, e.KeyType, e.Rows, e.Layout, e.Built.Format(time.RFC3339), e.Source)
+	}
+	return w.Flush()
+}
+
+func refreshIndex(c *kingpin.ParseContext) error {
+	path, err := resolveIndex(*refreshIndexFile, *refreshCatalog, *refreshTable, *refreshColumn)
+	if err != nil {
+		return err
+	}
+	storeFile, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	// a retrain replaces the file of the backend, which closes the one it holds
+	backend := store.NewFileBackend(storeFile)
+	defer backend.Close()
+	idx, err := index.OpenDisk(backend)
+	if err != nil {
+		return err
+	}
+	if idx.Values != index.POINTERS {
+		return fmt.Errorf("%s doesn't point to the rows of a CSV, it can't be refreshed", path)
+	}
+	old := idx.Source
+	status, err := old.Status()
+	if err != nil {
+		return err
+	}
+	if status == index.FRESH {
+		fmt.Fprintf(stdout, "%s is up to date\n", path)
+		return nil
+	}
+	if status != index.APPENDED {
+		return fmt.Errorf("the source %s is %s, rebuild the index with rmi create", old.Path, status)
+	}
 
-		// search an age and get back its line position inside the file people.csv
-		result, err := idx.Lookup(search)
+	// only the rows appended after the indexed bytes are parsed
+	src, err := index.StatSource(old.Path, old.Column)
+	if err != nil {
+		return err
+	}
+	csvfile, err := os.Open(old.Path)
+	if err != nil {
+		return err
+	}
+	defer csvfile.Close()
+	last := make([]byte, 1)
+	if _, err := csvfile.ReadAt(last, old.Size-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		return fmt.Errorf("the last indexed row of %s had no line terminator, rebuild the index with rmi create", old.Path)
+	}
+	format := old.Format
+	field, _, err := format.Locate(format.NewScanner(csvfile), old.Column)
+	if err != nil {
+		return fmt.Errorf("%s: %s", old.Path, err)
+	}
+	column, err := format.ScanColumn(format.NewScanner(io.NewSectionReader(csvfile, old.Size, src.Size-old.Size)), field, old.Size)
+	if err != nil {
+		return err
+	}
+	src.Format = format
+	keys := column.Keys
+	values := make([]uint64, len(column.Pointers))
+	for i, p := range column.Pointers {
+		values[i] = uint64(p)
+	}
+	retrained, err := idx.Insert(keys, values, src, store.Options{PageSize: *refreshPageSize})
+	if err != nil {
+		return err
+	}
+
+	// update the manifest when the index belongs to the catalog
+	cat, err := catalog.Open(*refreshCatalog)
+	if err != nil {
+		return err
+	}
+	for _, e := range cat.Entries {
+		if filepath.Clean(cat.Path(e)) != filepath.Clean(path) {
+			continue
+		}
+		entry, err := catalog.Describe(e.Name, idx)
 		if err != nil {
-			log.Fatalf("There is no entry found for %s inside %s \n", os.Args[1], file)
+			return err
 		}
-		lines := []int{}
-		for _, l := range result {
-			lines = append(lines, l+FIRST_LINE_OF_DATA)
+		entry.Built = time.Now().UTC()
+		if err := cat.Add(entry); err != nil {
+			return err
 		}
-		log.Printf("We found %d entries in the index \n", len(lines))
-		log.Printf("People who are %s years old are located at %d inside %s \n", os.Args[1], lines, file)
-	*/
+	}
+	if retrained {
+		fmt.Fprintf(stdout, "%d appended rows indexed in %s, %d rejected, the model was retrained over %d rows\n", len(keys), path, column.Rejected, idx.Count())
+	} else {
+		fmt.Fprintf(stdout, "%d appended rows indexed in %s, %d rejected\n", len(keys), path, column.Rejected)
+	}
 	return nil
 }
 
-func plotIndex(c *kingpin.ParseContext) error {
-	// ageColumn := extractColumn(file, "age")
-	//img, _ := filepath.Abs(*plotPath)
-	//index.Genplot(idx, ageColumn, img, *plotAction)
+func statusIndexes(c *kingpin.ParseContext) error {
+	cat, err := catalog.Open(*statusCatalog)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tSOURCE")
+	for _, e := range cat.Entries {
+		status, err := indexStatus(cat.Path(e))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, status, e.Source)
+	}
+	return w.Flush()
+}
+
+func indexStatus(path string) (index.SourceStatus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return index.UNKNOWN, err
+	}
+	defer f.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(f))
+	if err != nil {
+		return index.UNKNOWN, err
+	}
+	return idx.Source.Status()
+}
+
+/*
+checkSource warns or fails, according to the policy, when the source of the index has changed since it was built
+*/
+func checkSource(path string, source index.Source, policy string) error {
+	if policy == STALE_IGNORE {
+		return nil
+	}
+	status, err := source.Status()
+	if err != nil {
+		return err
+	}
+	if !status.Stale() {
+		return nil
+	}
+	msg := fmt.Sprintf("%s: the source %s has changed (%s) since the index was built", path, source.Path, status)
+	if policy == STALE_REFUSE {
+		return fmt.Errorf("%s", msg)
+	}
+	fmt.Fprintln(stderr, "warning:", msg)
 	return nil
 }
 
-func extractColumn(file string, colName string) []float64 {
-	csvfile, _ := os.Open(file)
-	r := csv.NewReader(csvfile)
+func dropIndex(c *kingpin.ParseContext) error {
+	cat, err := catalog.Open(*dropCatalog)
+	if err != nil {
+		return err
+	}
+	name := *dropName
+	if name == "" {
+		if *dropColumn == "" {
+			return fmt.Errorf("designate the index by its name or by --table and --column")
+		}
+		e, err := cat.Find(*dropTable, *dropColumn)
+		if err != nil {
+			return err
+		}
+		name = e.Name
+	}
+	return cat.Drop(name)
+}
 
-	var valuesColumn []float64
-	var ageCid int
-	var headerLine bool = true
-	for {
-		// Read each record from csv
-		record, err := r.Read()
-		if err == io.EOF {
-			break
+/*
+resolveIndex returns the index file given by --index, otherwise the index of the column of the table
+found in the catalog. Without column, the catalog must hold a single index
+*/
+func resolveIndex(file, dir, table, column string) (string, error) {
+	if file != "" {
+		return file, nil
+	}
+	cat, err := catalog.Open(dir)
+	if err != nil {
+		return "", err
+	}
+	if column == "" && table == "" && len(cat.Entries) == 1 {
+		return cat.Path(cat.Entries[0]), nil
+	}
+	if column == "" {
+		return "", fmt.Errorf("%s holds %d indexes, choose one with --table and --column or --index", dir, len(cat.Entries))
+	}
+	e, err := cat.Find(table, column)
+	if err != nil {
+		return "", err
+	}
+	return cat.Path(e), nil
+}
+
+func selectWhere(c *kingpin.ParseContext) error {
+	if *selectKeysFrom != "" {
+		if len(*searchedValues) > 0 {
+			return fmt.Errorf("the keys are either given as arguments or read with --keys-from, not both")
+		}
+		return searchKeys(*selectKeysFrom, *selectKeysCol)
+	}
+	if *selectKeysCol != "" {
+		return fmt.Errorf("--keys-column reads the keys of --keys-from")
+	}
+	if len(*searchedValues) != 1 || strings.Contains((*searchedValues)[0], "=") {
+		return selectPredicates(*searchedValues)
+	}
+	search, err := strconv.ParseFloat((*searchedValues)[0], 64)
+	if err != nil {
+		return err
+	}
+	path, err := resolveIndex(*selectIndexFile, *selectCatalog, *selectTable, *selectColumn)
+	if err != nil {
+		return err
+	}
+	storeFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer storeFile.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(storeFile))
+	if err != nil {
+		return err
+	}
+	if err := checkSource(path, idx.Source, *selectStale); err != nil {
+		return err
+	}
+
+	src, err := openSource(idx)
+	if err != nil {
+		return err
+	}
+	if src != nil {
+		defer src.Close()
+	}
+
+	// search a key and get back the rows location inside the indexed file, all of them when the key is duplicated
+	out := newResultWriter(stdout, stderr, *selectFormat)
+	out.begin(keyQuery(idx.Source, search))
+	result, err := idx.Postings(search)
+	if err != nil {
+		return err
+	}
+	if err := writeMatches(out, idx.Source, src, &search, result.Offsets()); err != nil {
+		return err
+	}
+	out.end()
+	return out.close()
+}
+
+// keyQuery describes the lookup of a key like column=key
+func keyQuery(source index.Source, key float64) string {
+	return fmt.Sprintf("%s=%v", source.Column, key)
+}
+
+// openSource opens the CSV whose rows are pointed by the index, nil when the values are positions
+func openSource(idx *index.DiskIndex) (*os.File, error) {
+	if idx.Values != index.POINTERS {
+		return nil, nil
+	}
+	return os.Open(idx.Source.Path)
+}
+
+func rangeRows(c *kingpin.ParseContext) error {
+	if *rangeFrom > *rangeTo {
+		return fmt.Errorf("--from %v is greater than --to %v", *rangeFrom, *rangeTo)
+	}
+	path, err := resolveIndex(*rangeIndexFile, *rangeCatalog, *rangeTable, *rangeColumn)
+	if err != nil {
+		return err
+	}
+	storeFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer storeFile.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(storeFile))
+	if err != nil {
+		return err
+	}
+	if err := checkSource(path, idx.Source, *rangeStale); err != nil {
+		return err
+	}
+	src, err := openSource(idx)
+	if err != nil {
+		return err
+	}
+	if src != nil {
+		defer src.Close()
+	}
+
+	// the rows are read one by one while the index walks the keys, positions are printed without source
+	in := index.Interval{From: *rangeFrom, To: *rangeTo, ExcludeFrom: !*rangeIncludeFrom, ExcludeTo: *rangeExcludeTo}
+	out := newResultWriter(stdout, stderr, *rangeFormat)
+	out.begin(intervalQuery(idx.Source.Column, in))
+	n := 0
+	idx.Range(in, func(key float64, value uint64) bool {
+		var m match
+		if m, err = rowMatch(src, &key, value); err != nil {
+			return false
+		}
+		if err = out.write(m); err != nil {
+			return false
 		}
+		n++
+		return n != *rangeLimit
+	})
+	if err != nil {
+		return err
+	}
+	out.end()
+	return out.close()
+}
+
+// intervalQuery describes the interval like column in [from, to)
+func intervalQuery(column string, in index.Interval) string {
+	open, closed := "[", "]"
+	if in.ExcludeFrom {
+		open = "("
+	}
+	if in.ExcludeTo {
+		closed = ")"
+	}
+	return fmt.Sprintf("%s in %s%v, %v%s", column, open, in.From, in.To, closed)
+}
+
+func selectPredicates(args []string) error {
+	predicates := make([]predicate, len(args))
+	for i, a := range args {
+		p, err := parsePredicate(a)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		if headerLine {
-			for i, c := range record {
-				if strings.ToLower(c) == colName {
-					ageCid = i
-				}
+		predicates[i] = p
+	}
+	cat, err := catalog.Open(*selectCatalog)
+	if err != nil {
+		return err
+	}
+	out := newResultWriter(stdout, stderr, *selectFormat)
+	out.begin(strings.Join(args, " "))
+	source, pointers, err := matchRows(cat, *selectTable, predicates, *selectAny, *selectStale)
+	if err != nil {
+		return err
+	}
+	if len(pointers) == 0 {
+		return fmt.Errorf("no row matches %s", strings.Join(args, " "))
+	}
+	src, err := os.Open(source.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := writeMatches(out, source, src, nil, pointers); err != nil {
+		return err
+	}
+	out.end()
+	return out.close()
+}
+
+/*
+writeMatches writes the rows located by the pointers in src, or their line numbers with --lines-only.
+Without src, the pointers are the positions of the rows
+*/
+func writeMatches(out *resultWriter, source index.Source, src *os.File, key *float64, pointers []int) error {
+	lines, err := matchLines(source, src, pointers)
+	if err != nil {
+		return err
+	}
+	return writeRows(out, src, key, pointers, lines)
+}
+
+// matchLines returns the line numbers of the rows located by the pointers with --lines-only, nil otherwise
+func matchLines(source index.Source, src *os.File, pointers []int) (map[int64]int, error) {
+	if src == nil || !*selectLinesOnly {
+		return nil, nil
+	}
+	return lineNumbers(source, src, pointers)
+}
+
+// writeRows writes the rows located by the pointers in src, or their line numbers when lines maps their offsets
+func writeRows(out *resultWriter, src *os.File, key *float64, pointers []int, lines map[int64]int) error {
+	if src == nil {
+		for _, o := range pointers {
+			if err := out.write(match{Key: key, Offset: int64(o)}); err != nil {
+				return err
 			}
-			headerLine = false
-			continue
 		}
-		v, _ := strconv.ParseFloat(record[ageCid], 64)
-		valuesColumn = append(valuesColumn, v)
+		return nil
+	}
+	for _, o := range pointers {
+		p := table.Pointer(o)
+		m := match{Key: key, Offset: p.Offset(), Length: p.Length(), Line: lines[p.Offset()]}
+		if lines == nil {
+			row, err := p.ReadAt(src)
+			if err != nil {
+				return err
+			}
+			m.Row = string(row)
+		}
+		if err := out.write(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineNumbers scans the source until the rows located by the pointers are found, and maps their offset to their first line number
+func lineNumbers(source index.Source, src *os.File, pointers []int) (map[int64]int, error) {
+	lines := make(map[int64]int, len(pointers))
+	for _, o := range pointers {
+		lines[table.Pointer(o).Offset()] = 0
+	}
+	r := source.Format.NewScanner(io.NewSectionReader(src, 0, math.MaxInt64))
+	for found := 0; found < len(lines) && r.Scan(); {
+		row := r.Row()
+		if line, ok := lines[row.Offset]; ok && line == 0 {
+			lines[row.Offset] = row.Line
+			found++
+		}
+	}
+	return lines, r.Err()
+}
+
+func inspectIndex(c *kingpin.ParseContext) error {
+	path, err := resolveIndex(*inspectIndexFile, *inspectCatalog, *inspectTable, *inspectColumn)
+	if err != nil {
+		return err
+	}
+	storeFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer storeFile.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(storeFile))
+	if err != nil {
+		return err
+	}
+	r, err := idx.Inspect()
+	if err != nil {
+		return err
+	}
+	if *inspectJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	params := []string{}
+	for k, v := range r.Params {
+		params = append(params, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(params)
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "file\t%s\n", path)
+	fmt.Fprintf(w, "version\t%d\n", r.Version)
+	fmt.Fprintf(w, "layout\t%s\n", r.Layout)
+	fmt.Fprintf(w, "values\t%s\n", r.Values)
+	fmt.Fprintf(w, "records\t%d, %d in the delta\n", r.Records, r.Delta)
+	fmt.Fprintf(w, "keys\t[%v, %v]\n", r.KeyMin, r.KeyMax)
+	fmt.Fprintf(w, "estimator\t%s %s\n", r.Estimator, strings.Join(params, " "))
+	fmt.Fprintf(w, "error bounds\t[%d, %d]\n", r.MinErrBound, r.MaxErrBound)
+	for i, m := range r.Models {
+		fmt.Fprintf(w, "model %d.%d\t%d keys, mean abs error %.2f, max abs error %d, mean window %.2f records\n", m.Stage, i, m.Keys, m.MeanAbsError, m.MaxAbsError, m.MeanWindow)
+	}
+	sec := r.Sections
+	fmt.Fprintf(w, "sections\theader %d B, meta %d B, padding %d B, records %d B, trailer %d B\n", sec.Header, sec.Meta, sec.Padding, sec.Records, sec.Trailer)
+	fmt.Fprintf(w, "checksums\t%t\n", r.Checksummed)
+	if r.Source != "" {
+		fmt.Fprintf(w, "source\t%s, column %s\n", r.Source, r.Column)
+	}
+	return w.Flush()
+}
+
+func plotIndex(c *kingpin.ParseContext) error {
+	path, err := resolveIndex(*plotIndexFile, *plotCatalog, *plotTable, *plotColumn)
+	if err != nil {
+		return err
+	}
+	storeFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer storeFile.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(storeFile))
+	if err != nil {
+		return err
+	}
+	column := idx.Source.Column
+	if column == "" {
+		column = "Key"
+	}
+	img := filepath.Join(*plotDir, strings.TrimSuffix(filepath.Base(path), catalog.INDEX_EXT)+"."+*plotExt)
+	if err := index.Genplot(idx.Load(), column, img, *plotSmoothBoundaries); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, img)
+	return nil
+}
+
+/*
+extractColumn parses the column of the first size bytes of the CSV file, designated by its name or by the position in format.
+The position found is recorded in format, the name of the column is returned
+*/
+func extractColumn(file string, colName string, format *table.Format, size int64) (table.Column, string, error) {
+	csvfile, err := os.Open(file)
+	if err != nil {
+		return table.Column{}, "", err
+	}
+	defer csvfile.Close()
+	r := format.NewScanner(io.NewSectionReader(csvfile, 0, size))
+	field, name, err := format.Locate(r, colName)
+	if err != nil {
+		return table.Column{}, "", fmt.Errorf("%s: %s", file, err)
+	}
+	format.Field = field
+	column, err := format.ScanColumn(r, field, 0)
+	if err != nil {
+		return column, name, fmt.Errorf("%s: %s", file, err)
 	}
-	return valuesColumn
+	return column, name, nil
 }
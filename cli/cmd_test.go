@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/table"
+	"github.com/stretchr/testify/assert"
+)
+
+const PEOPLE = "name,age,sex\njeanne,90,F\njean,23,M\n\"Dupont,\nMarie\",3,F\nGeorgette,23,F\npaul,45,M\n"
+
+// newCatalog indexes the age column of PEOPLE in a new catalog, and returns its directory
+func newCatalog(t *testing.T) string {
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "people.csv")
+	ioutil.WriteFile(csv, []byte(PEOPLE), 0644)
+	_, _, err := run("create", "-f", csv, "-c", "age", "--catalog", dir)
+	assert.NoError(t, err)
+	return dir
+}
+
+// run parses the command line and runs its action, it returns what was printed on stdout and on stderr
+func run(args ...string) (string, string, error) {
+	var out, diag bytes.Buffer
+	stdout, stderr = &out, &diag
+	// kingpin appends the repeated arguments to the values of the previous parse,
+	// and leaves the flags without default to the value they were given
+	*searchedValues = nil
+	*columnToIndex, *noHeader, *createOutput, *createName = "", false, "", ""
+	*selectIndexFile, *selectLinesOnly, *selectAny, *selectKeysFrom = "", false, false, ""
+	*joinNoHeader, *joinRightColumn = false, ""
+	*rangeIndexFile, *rangeTable, *rangeColumn, *rangeExcludeTo, *rangeLimit = "", "", "", false, 0
+	*refreshIndexFile, *refreshTable, *refreshColumn = "", "", ""
+	*verifyIndexFile, *verifyTable, *verifyColumn, *verifyCSV = "", "", "", false
+	*plotIndexFile, *plotTable, *plotColumn = "", "", ""
+	*inspectIndexFile, *inspectTable, *inspectColumn, *inspectJSON = "", "", "", false
+	*benchQueries, *benchJSON = "", false
+	defer func() {
+		stdout, stderr = os.Stdout, os.Stderr
+	}()
+	_, err := app.Parse(args)
+	return out.String(), diag.String(), err
+}
+
+func TestSearch(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+
+	// when
+	out, diag, err := run("search", "--catalog", dir, "23")
+
+	// then every duplicate is printed, in the order of the file
+	assert.NoError(t, err)
+	assert.Equal(t, "jean,23,M\nGeorgette,23,F\n", out)
+	assert.True(t, strings.HasPrefix(diag, "2 rows matching age=23 in "), diag)
+
+	// when a row spans several lines
+	out, _, err = run("search", "--catalog", dir, "3")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "\"Dupont,\nMarie\",3,F\n", out)
+}
+
+func TestSearch_LinesOnly(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+
+	// when
+	out, _, err := run("search", "--catalog", dir, "--lines-only", "23")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "3\n6\n", out)
+
+	// when the row before spans 2 lines
+	out, _, err = run("search", "--catalog", dir, "--lines-only", "45")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "7\n", out)
+}
+
+func TestSearch_Miss(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+
+	// when
+	out, _, err := run("search", "--catalog", dir, "24")
+
+	// then the command fails, so rmi exits with a non-zero code
+	assert.Error(t, err)
+	assert.Empty(t, out)
+}
+
+func TestSearch_KeysFromLinesOnly(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+	keys := filepath.Join(dir, "keys.txt")
+	ioutil.WriteFile(keys, []byte("45\n23\n24\n3\n"), 0644)
+
+	// when
+	out, diag, err := run("search", "--catalog", dir, "--keys-from", keys, "--lines-only")
+
+	// then the lines of the whole batch are printed in the order of the keys, a missing key is not an error
+	assert.NoError(t, err)
+	assert.Equal(t, "7\n3\n6\n4\n", out)
+	assert.Equal(t, 4, strings.Count(diag, "rows matching"))
+	assert.Contains(t, diag, "0 rows matching age=24 in ")
+}
+
+func TestCreate_NotFinite(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "people.csv")
+	ioutil.WriteFile(csv, []byte("name,age\njeanne,90\njean,NaN\npaul,Inf\n"), 0644)
+
+	// when NaN and Inf are skipped as bad values
+	_, _, err := run("create", "-f", csv, "-c", "age", "--catalog", dir)
+	// then the manifest is saved and the finite keys are found
+	assert.NoError(t, err)
+	out, _, err := run("search", "--catalog", dir, "90")
+	assert.NoError(t, err)
+	assert.Equal(t, "jeanne,90\n", out)
+
+	// when the default key is not finite
+	_, _, err = run("create", "-f", csv, "-c", "age", "--catalog", dir, "--bad-values", "default", "--default", "NaN")
+	// then
+	assert.EqualError(t, err, "the default key must be a finite number, got NaN")
+}
+
+func TestCreate_Options(t *testing.T) {
+	// given a CSV without header, separated by semicolons
+	dir := t.TempDir()
+	csv, out := filepath.Join(dir, "people.csv"), filepath.Join(dir, "out.rmi")
+	ioutil.WriteFile(csv, []byte("jeanne;90\njean;x\npaul;45\n"), 0644)
+	create := []string{"create", "-f", csv, "--catalog", dir, "--no-header", "--column-index", "1", "-d", ";"}
+
+	// when the bad values fail the build
+	_, _, err := run(append(create, "--bad-values", "fail")...)
+	// then
+	assert.EqualError(t, err, csv+`: line 2: "x" is not a number`)
+
+	// when they are indexed under the default key, outside of the catalog
+	stdout, _, err := run(append(create, "--bad-values", "default", "--default=-1", "-o", out)...)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "3 rows indexed in "+out+", 0 rejected\n", stdout)
+	rows, _, err := run("search", "-i", out, "--", "-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "jean;x\n", rows)
+	list, _, _ := run("list", "--catalog", dir)
+	assert.Equal(t, 1, strings.Count(list, "\n"), "-o leaves the catalog out")
+
+	// when they are skipped, under another name
+	stdout, _, err = run(append(create, "--name", "people.ages")...)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "2 rows indexed in "+filepath.Join(dir, "people.ages.rmi")+", 1 rejected\n", stdout)
+	list, _, _ = run("list", "--catalog", dir)
+	assert.Contains(t, list, "people.ages ")
+}
+
+func TestExtractColumn_Size(t *testing.T) {
+	// given
+	csv := filepath.Join(t.TempDir(), "people.csv")
+	ioutil.WriteFile(csv, []byte(PEOPLE), 0644)
+	format := table.DefaultFormat()
+	size := int64(strings.Index(PEOPLE, "\"Dupont"))
+
+	// when only the first size bytes were recorded
+	column, name, err := extractColumn(csv, "age", &format, size)
+
+	// then the rows after them are not parsed
+	assert.NoError(t, err)
+	assert.Equal(t, "age", name)
+	assert.Equal(t, []float64{90, 23}, column.Keys)
+}
+
+func TestRange(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+
+	// when
+	out, _, err := run("range", "--catalog", dir, "--from", "3", "--to", "45")
+	// then the rows are printed in key order, the duplicates in the order of the file
+	assert.NoError(t, err)
+	assert.Equal(t, "\"Dupont,\nMarie\",3,F\njean,23,M\nGeorgette,23,F\npaul,45,M\n", out)
+
+	// when both bounds are excluded
+	out, _, err = run("range", "--catalog", dir, "--from", "3", "--to", "45", "--no-inclusive-from", "--exclusive-to")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "jean,23,M\nGeorgette,23,F\n", out)
+
+	// when limited
+	out, _, err = run("range", "--catalog", dir, "--from", "23", "--to", "100", "--limit", "2")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "jean,23,M\nGeorgette,23,F\n", out)
+
+	// when the bounds are inverted
+	_, _, err = run("range", "--catalog", dir, "--from", "45", "--to", "3")
+	// then
+	assert.EqualError(t, err, "--from 45 is greater than --to 3")
+}
+
+func TestList(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+
+	// when
+	out, _, err := run("list", "--catalog", dir)
+
+	// then
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "NAME "), lines[0])
+	assert.Equal(t, []string{"people.age", "people", "age", "float64", "5", "interleaved"}, strings.Fields(lines[1])[:6])
+	assert.Equal(t, filepath.Join(dir, "people.csv"), strings.Fields(lines[1])[7])
+}
+
+func TestStatus(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+	csv := filepath.Join(dir, "people.csv")
+
+	// when
+	out, _, err := run("status", "--catalog", dir)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"NAME", "STATUS", "SOURCE", "people.age", "fresh", csv}, strings.Fields(out))
+
+	// when a row is appended to the source
+	ioutil.WriteFile(csv, []byte(PEOPLE+"max,12,M\n"), 0644)
+	out, _, err = run("status", "--catalog", dir)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "appended", strings.Fields(out)[4])
+
+	// when it is rewritten
+	ioutil.WriteFile(csv, []byte(strings.Replace(PEOPLE, "90", "91", 1)), 0644)
+	out, _, err = run("status", "--catalog", dir)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "changed", strings.Fields(out)[4])
+}
+
+func TestRefresh(t *testing.T) {
+	// given rows appended to the source of an index
+	dir := newCatalog(t)
+	csv := filepath.Join(dir, "people.csv")
+	ioutil.WriteFile(csv, []byte(PEOPLE+"max,23,M\nzoe,one,F\n"), 0644)
+	path := filepath.Join(dir, "people.age.rmi")
+
+	// when
+	out, _, err := run("refresh", "--catalog", dir, "-t", "people", "-c", "age")
+
+	// then the appended rows are found, the delta being larger than 1/8 of the records the model is retrained
+	assert.NoError(t, err)
+	assert.Equal(t, "1 appended rows indexed in "+path+", 1 rejected, the model was retrained over 6 rows\n", out)
+	out, _, err = run("search", "--catalog", dir, "23")
+	assert.NoError(t, err)
+	assert.Equal(t, "jean,23,M\nGeorgette,23,F\nmax,23,M\n", out)
+
+	// when nothing was appended since
+	out, _, err = run("refresh", "--catalog", dir, "-t", "people", "-c", "age")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, path+" is up to date\n", out)
+}
+
+func TestVerify(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+	csv := filepath.Join(dir, "people.csv")
+	path := filepath.Join(dir, "people.age.rmi")
+
+	// when
+	out, _, err := run("verify", "--catalog", dir, "-t", "people", "-c", "age", "--csv")
+	// then
+	assert.NoError(t, err)
+	lines := strings.Split(out, "\n")
+	assert.True(t, strings.HasPrefix(lines[0], path+": 5 records, interleaved layout"), lines[0])
+	assert.Equal(t, []string{"5 rows of " + csv + " compared with a full scan", "ok", ""}, lines[1:])
+
+	// when the source has changed since the index was built
+	ioutil.WriteFile(csv, []byte(strings.Replace(PEOPLE, "90", "91", 1)), 0644)
+	out, _, err = run("verify", "--catalog", dir, "-t", "people", "-c", "age", "--csv")
+	// then the problem is printed and the command fails
+	assert.EqualError(t, err, path+": 1 problems found")
+	assert.Contains(t, out, "  - the source "+csv+" has changed (changed) since the index was built")
+}
+
+func TestPlot(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+
+	// when
+	out, _, err := run("plot", "--catalog", dir, "--table", "people", "-c", "age", "-d", dir, "-t", "svg")
+
+	// then the path of the image is printed
+	img := filepath.Join(dir, "people.age.svg")
+	assert.NoError(t, err)
+	assert.Equal(t, img+"\n", out)
+	assert.FileExists(t, img)
+}
+
+func TestInspect(t *testing.T) {
+	// given
+	dir := newCatalog(t)
+	path := filepath.Join(dir, "people.age.rmi")
+
+	// when
+	out, _, err := run("inspect", "--catalog", dir, "-t", "people", "-c", "age")
+	// then
+	assert.NoError(t, err)
+	assert.Contains(t, out, "file          "+path+"\n")
+	assert.Contains(t, out, "records       5, 0 in the delta\n")
+	assert.Contains(t, out, "keys          [3, 90]\n")
+
+	// when as JSON
+	out, _, err = run("inspect", "-i", path, "--json")
+	// then
+	assert.NoError(t, err)
+	var r index.Report
+	assert.NoError(t, json.Unmarshal([]byte(out), &r))
+	assert.Equal(t, 5, r.Records)
+	assert.Equal(t, 90.0, r.KeyMax)
+}
@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/catalog"
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+/*
+joinRows writes, for each row of the left CSV, the rows of the right table holding its key in the right index.
+The keys are sought with an index.Cursor: sorted left rows are merged with the records of the index,
+the other ones are located with the model
+*/
+func joinRows(c *kingpin.ParseContext) error {
+	format := table.Format{Header: !*joinNoHeader, Field: -1}
+	if err := setDelimiters(&format, *joinDelimiter, *joinQuote); err != nil {
+		return err
+	}
+	if *joinNoHeader {
+		field, err := strconv.Atoi(*joinOn)
+		if err != nil || field < 0 {
+			return fmt.Errorf("without header, --on must be the position of the column, got %q", *joinOn)
+		}
+		format.Field = field
+	}
+	cat, err := catalog.Open(*joinCatalog)
+	if err != nil {
+		return err
+	}
+	path, err := joinIndex(cat, *joinRightIndex, *joinRightColumn)
+	if err != nil {
+		return err
+	}
+	storeFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer storeFile.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(storeFile))
+	if err != nil {
+		return err
+	}
+	if idx.Values != index.POINTERS {
+		return fmt.Errorf("%s doesn't point to the rows of a CSV, it can't be joined", path)
+	}
+	if err := checkSource(path, idx.Source, *joinStale); err != nil {
+		return err
+	}
+	return joinCSV(stdout, stderr, index.Source{Path: *joinLeft, Format: format}, *joinOn, idx)
+}
+
+/*
+joinCSV writes to w, as CSV, the rows of the left CSV joined with the rows of the source of idx holding the key
+of their column on, and the summary of the join to diag. The left columns are named by their position without header
+*/
+func joinCSV(w, diag io.Writer, left index.Source, on string, idx *index.DiskIndex) error {
+	right, err := os.Open(idx.Source.Path)
+	if err != nil {
+		return err
+	}
+	defer right.Close()
+	rightHeader, err := header(idx.Source, right)
+	if err != nil {
+		return err
+	}
+
+	leftFile, err := os.Open(left.Path)
+	if err != nil {
+		return err
+	}
+	defer leftFile.Close()
+	leftHeader, err := header(left, leftFile)
+	if err != nil {
+		return err
+	}
+	s := left.Format.NewScanner(leftFile)
+	field, _, err := left.Format.Locate(s, on)
+	if err != nil {
+		return fmt.Errorf("%s: %s", left.Path, err)
+	}
+
+	// the columns of the right table named like a column of the left one are prefixed by the table
+	bw := bufio.NewWriter(w)
+	out := csv.NewWriter(bw)
+	names := map[string]bool{}
+	for _, n := range leftHeader {
+		names[strings.ToLower(n)] = true
+	}
+	columns := append([]string{}, leftHeader...)
+	for _, n := range rightHeader {
+		if names[strings.ToLower(n)] {
+			n = catalog.TableName(idx.Source.Path) + "." + n
+		}
+		columns = append(columns, n)
+	}
+	out.Write(columns)
+
+	start := time.Now()
+	cursor := idx.Cursor()
+	var leftRows, joined, unmatched, skipped int
+	var previous float64
+	var matches [][]string
+	for s.Scan() {
+		row := s.Row()
+		leftRows++
+		if field >= len(row.Fields) {
+			return fmt.Errorf("%s: line %d: the row has only %d fields", left.Path, row.Line, len(row.Fields))
+		}
+		key, err := strconv.ParseFloat(strings.TrimSpace(row.Fields[field]), 64)
+		if err != nil {
+			skipped++
+			continue
+		}
+		// consecutive rows holding the same key join the same right rows
+		if matches == nil || key != previous {
+			if matches, err = rightRows(idx.Source, right, cursor.Seek(key)); err != nil {
+				return err
+			}
+			previous = key
+		}
+		if len(matches) == 0 {
+			unmatched++
+		}
+		for _, m := range matches {
+			if err := out.Write(append(append([]string{}, row.Fields...), m...)); err != nil {
+				return err
+			}
+			joined++
+		}
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("%s: %s", left.Path, err)
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	strategy := "merged with the index, the keys are sorted"
+	if !cursor.Sorted() {
+		strategy = "looked up in the index, the keys are not sorted"
+	}
+	fmt.Fprintf(diag, "%d rows of %s %s: %d joined rows, %d without match, %d skipped in %s\n",
+		leftRows, left.Path, strategy, joined, unmatched, skipped, time.Since(start))
+	return nil
+}
+
+/*
+joinIndex returns the path of the index named name, or else of the index of the table named name over the column.
+Without column, the table must have a single index
+*/
+func joinIndex(cat *catalog.Catalog, name, column string) (string, error) {
+	if column != "" {
+		e, err := cat.Find(name, column)
+		if err != nil {
+			return "", err
+		}
+		return cat.Path(e), nil
+	}
+	if e, ok := cat.Get(name); ok {
+		return cat.Path(e), nil
+	}
+	var found []catalog.Entry
+	for _, e := range cat.Entries {
+		if e.Table == strings.ToLower(name) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no index named %q nor over the table %q in %s", name, name, cat.Dir)
+	case 1:
+		return cat.Path(found[0]), nil
+	}
+	return "", fmt.Errorf("the table %q has %d indexes, choose one with --right-column", name, len(found))
+}
+
+// header returns the names of the columns of the source, their positions when it has no header row
+func header(source index.Source, src *os.File) ([]string, error) {
+	s := source.Format.NewScanner(io.NewSectionReader(src, 0, math.MaxInt64))
+	if !s.Scan() {
+		if s.Err() != nil {
+			return nil, fmt.Errorf("%s: %s", source.Path, s.Err())
+		}
+		return nil, fmt.Errorf("%s is empty", source.Path)
+	}
+	fields := s.Row().Fields
+	if !source.Format.Header {
+		for i := range fields {
+			fields[i] = strconv.Itoa(i)
+		}
+	}
+	return fields, nil
+}
+
+// rightRows reads and splits the rows pointed by the values
+func rightRows(source index.Source, src *os.File, values []uint64) ([][]string, error) {
+	rows := make([][]string, 0, len(values))
+	for _, v := range values {
+		raw, err := table.Pointer(v).ReadAt(src)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := table.Split(raw, source.Format.Comma, source.Format.Quote)
+		if err != nil {
+			return nil, fmt.Errorf("%s: offset %d: %s", source.Path, table.Pointer(v).Offset(), err)
+		}
+		rows = append(rows, fields)
+	}
+	return rows, nil
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/catalog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoin(t *testing.T) {
+	// given a left CSV sharing the column name with the people
+	dir := newCatalog(t)
+	left := filepath.Join(dir, "visits.csv")
+	ioutil.WriteFile(left, []byte("name,age\nparis,23\nlyon,45\n"), 0644)
+
+	// when
+	out, diag, err := run("join", "--catalog", dir, "--left", left, "--on", "age", "--right-index", "people")
+
+	// then the colliding columns of the right table are prefixed by its name
+	assert.NoError(t, err)
+	assert.Equal(t, "name,age,people.name,people.age,sex\n"+
+		"paris,23,jean,23,M\nparis,23,Georgette,23,F\nlyon,45,paul,45,M\n", out)
+	assert.True(t, strings.HasPrefix(diag, "2 rows of "+left+" merged with the index, the keys are sorted: 3 joined rows, 0 without match, 0 skipped in "), diag)
+}
+
+func TestJoin_Counts(t *testing.T) {
+	// given a left CSV whose keys aren't sorted, a row without number and a row without match
+	dir := newCatalog(t)
+	left := filepath.Join(dir, "visits.csv")
+	ioutil.WriteFile(left, []byte("city,years\nparis,45\nlyon,unknown\nnice,24\nlille,23\n"), 0644)
+
+	// when
+	out, diag, err := run("join", "--catalog", dir, "--left", left, "--on", "years", "--right-index", "people.age")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "city,years,name,age,sex\nparis,45,paul,45,M\nlille,23,jean,23,M\nlille,23,Georgette,23,F\n", out)
+	assert.Contains(t, diag, "4 rows of "+left+" looked up in the index, the keys are not sorted: 3 joined rows, 1 without match, 1 skipped in ")
+}
+
+func TestJoin_NoHeader(t *testing.T) {
+	// given a left CSV without header, delimited by semicolons
+	dir := newCatalog(t)
+	left := filepath.Join(dir, "visits.csv")
+	ioutil.WriteFile(left, []byte("paris;3\nlyon;90\n"), 0644)
+
+	// when
+	out, _, err := run("join", "--catalog", dir, "--left", left, "--on", "1", "--left-no-header", "--left-delimiter", ";", "--right-index", "people")
+
+	// then the left columns are named by their position
+	assert.NoError(t, err)
+	assert.Equal(t, "0,1,name,age,sex\nparis,3,\"Dupont,\nMarie\",3,F\nlyon,90,jeanne,90,F\n", out)
+
+	// when the column is designated by its name
+	_, _, err = run("join", "--catalog", dir, "--left", left, "--on", "age", "--left-no-header", "--right-index", "people")
+	// then
+	assert.EqualError(t, err, "without header, --on must be the position of the column, got \"age\"")
+}
+
+func TestJoinIndex(t *testing.T) {
+	// given a table with 2 indexes
+	cat := &catalog.Catalog{Dir: "catalog", Entries: []catalog.Entry{
+		{Name: "people.age", Table: "people", Column: "age", File: "people.age.rmi"},
+		{Name: "people.height", Table: "people", Column: "height", File: "people.height.rmi"},
+	}}
+
+	// when
+	_, err := joinIndex(cat, "people", "")
+	// then
+	assert.EqualError(t, err, "the table \"people\" has 2 indexes, choose one with --right-column")
+
+	// when
+	path, err := joinIndex(cat, "people", "height")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("catalog", "people.height.rmi"), path)
+
+	// when
+	path, err = joinIndex(cat, "people.age", "")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("catalog", "people.age.rmi"), path)
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/table"
+)
+
+const (
+	FORMAT_TEXT   = "text"
+	FORMAT_JSON   = "json"
+	FORMAT_NDJSON = "ndjson"
+	FORMAT_CSV    = "csv"
+)
+
+var outputFormats = []string{FORMAT_TEXT, FORMAT_JSON, FORMAT_NDJSON, FORMAT_CSV}
+
+/*
+match is a row found by a query. Offset and Length locate the row in the source CSV, without source Offset is
+the position of the row. Key is nil for the rows matching predicates, Line is only set with --lines-only
+*/
+type match struct {
+	Key     *float64 `json:"key,omitempty"`
+	Offset  int64    `json:"offset"`
+	Length  int64    `json:"length,omitempty"`
+	Line    int      `json:"line,omitempty"`
+	Row     string   `json:"row,omitempty"`
+	Elapsed int64    `json:"elapsed_ns"` // since the start of the query, when the row was found
+}
+
+/*
+queryResult is the JSON document of a query, the matches are listed in Rows
+*/
+type queryResult struct {
+	Query   string  `json:"query"`
+	Rows    []match `json:"rows"`
+	Count   int     `json:"count"`
+	Elapsed int64   `json:"elapsed_ns"`
+}
+
+/*
+resultWriter prints the matches of the queries to w in the chosen format.
+The text format prints the rows alone, and the count and the timing of each query to diag.
+The JSON document is written once all the queries have ended, the other formats stream the matches
+*/
+type resultWriter struct {
+	format  string
+	w       *bufio.Writer
+	diag    io.Writer
+	csv     *csv.Writer
+	start   time.Time
+	current queryResult
+	results []queryResult
+}
+
+func newResultWriter(w, diag io.Writer, format string) *resultWriter {
+	r := &resultWriter{format: format, w: bufio.NewWriter(w), diag: diag}
+	if format == FORMAT_CSV {
+		r.csv = csv.NewWriter(r.w)
+		r.csv.Write([]string{"query", "key", "offset", "length", "line", "row", "elapsed_ns"})
+	}
+	return r
+}
+
+// begin starts the timer of a query
+func (r *resultWriter) begin(query string) {
+	r.current = queryResult{Query: query, Rows: []match{}}
+	r.start = time.Now()
+}
+
+// beginAfter starts the timer of a query whose lookup already took spent
+func (r *resultWriter) beginAfter(query string, spent time.Duration) {
+	r.begin(query)
+	r.start = r.start.Add(-spent)
+}
+
+func (r *resultWriter) write(m match) error {
+	m.Elapsed = int64(time.Since(r.start))
+	r.current.Count++
+	switch r.format {
+	case FORMAT_JSON:
+		r.current.Rows = append(r.current.Rows, m)
+	case FORMAT_NDJSON:
+		line := struct {
+			Query string `json:"query"`
+			match
+		}{r.current.Query, m}
+		return json.NewEncoder(r.w).Encode(line)
+	case FORMAT_CSV:
+		key, line := "", ""
+		if m.Key != nil {
+			key = strconv.FormatFloat(*m.Key, 'g', -1, 64)
+		}
+		if m.Line > 0 {
+			line = strconv.Itoa(m.Line)
+		}
+		return r.csv.Write([]string{r.current.Query, key, strconv.FormatInt(m.Offset, 10), strconv.FormatInt(m.Length, 10), line, m.Row, strconv.FormatInt(m.Elapsed, 10)})
+	default:
+		switch {
+		case m.Line > 0:
+			fmt.Fprintln(r.w, m.Line)
+		case m.Row != "":
+			fmt.Fprintln(r.w, m.Row)
+		default:
+			fmt.Fprintln(r.w, m.Offset)
+		}
+	}
+	return nil
+}
+
+// end stops the timer of the query
+func (r *resultWriter) end() {
+	r.current.Elapsed = int64(time.Since(r.start))
+	if r.format == FORMAT_TEXT {
+		// the summary follows the rows of the query on a terminal
+		r.w.Flush()
+		fmt.Fprintf(r.diag, "%d rows matching %s in %s\n", r.current.Count, r.current.Query, time.Duration(r.current.Elapsed))
+	}
+	r.results = append(r.results, r.current)
+}
+
+/*
+close writes the JSON document, a single object for a single query, an array otherwise, and flushes the output
+*/
+func (r *resultWriter) close() error {
+	if r.format == FORMAT_JSON {
+		enc := json.NewEncoder(r.w)
+		enc.SetIndent("", "  ")
+		var doc interface{} = r.results
+		switch len(r.results) {
+		case 0:
+			doc = []queryResult{}
+		case 1:
+			doc = r.results[0]
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	if r.csv != nil {
+		r.csv.Flush()
+		if err := r.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return r.w.Flush()
+}
+
+/*
+rowMatch reads the row pointed by the value in src, without source the value is the position of the row
+*/
+func rowMatch(src *os.File, key *float64, value uint64) (match, error) {
+	if src == nil {
+		return match{Key: key, Offset: int64(value)}, nil
+	}
+	p := table.Pointer(value)
+	row, err := p.ReadAt(src)
+	if err != nil {
+		return match{}, err
+	}
+	return match{Key: key, Offset: p.Offset(), Length: p.Length(), Row: string(row)}, nil
+}
+
+/*
+countResult is the output of rmi count
+*/
+type countResult struct {
+	Index   string `json:"index"`
+	Count   int64  `json:"count"`
+	Elapsed int64  `json:"elapsed_ns"`
+}
+
+// printCount writes c to w in the chosen format
+func printCount(w io.Writer, format string, c countResult) error {
+	switch format {
+	case FORMAT_JSON, FORMAT_NDJSON:
+		return json.NewEncoder(w).Encode(c)
+	case FORMAT_CSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"index", "count", "elapsed_ns"})
+		cw.Write([]string{c.Index, strconv.FormatInt(c.Count, 10), strconv.FormatInt(c.Elapsed, 10)})
+		cw.Flush()
+		return cw.Error()
+	default:
+		_, err := fmt.Fprintln(w, c.Count)
+		return err
+	}
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// query writes the matches of a query to r
+func query(r *resultWriter, q string, matches ...match) {
+	r.begin(q)
+	for _, m := range matches {
+		r.write(m)
+	}
+	r.end()
+}
+
+func TestResultWriter_Text(t *testing.T) {
+	// given
+	var out, diag bytes.Buffer
+	r := newResultWriter(&out, &diag, FORMAT_TEXT)
+
+	// when
+	query(r, "age=23", match{Row: "jean,23,M"}, match{Row: "Georgette,23,F"})
+	query(r, "age=3", match{Line: 4})
+	query(r, "age=90", match{Offset: 7})
+	err := r.close()
+
+	// then the rows go to the output, the summaries to the diagnostics
+	assert.NoError(t, err)
+	assert.Equal(t, "jean,23,M\nGeorgette,23,F\n4\n7\n", out.String())
+	lines := strings.Split(strings.TrimSuffix(diag.String(), "\n"), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "2 rows matching age=23 in "), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "1 rows matching age=3 in "), lines[1])
+}
+
+func TestResultWriter_JSON(t *testing.T) {
+	key := 23.
+
+	// when a single query
+	var out, diag bytes.Buffer
+	r := newResultWriter(&out, &diag, FORMAT_JSON)
+	query(r, "23", match{Key: &key, Offset: 10, Length: 9, Row: "jean,23,M"})
+	assert.NoError(t, r.close())
+	// then a single object
+	var result queryResult
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Equal(t, "23", result.Query)
+	assert.Equal(t, 1, result.Count)
+	assert.Equal(t, []match{{Key: &key, Offset: 10, Length: 9, Row: "jean,23,M", Elapsed: result.Rows[0].Elapsed}}, result.Rows)
+	assert.Empty(t, diag.String())
+
+	// when many queries
+	out.Reset()
+	r = newResultWriter(&out, &diag, FORMAT_JSON)
+	query(r, "23")
+	query(r, "24")
+	assert.NoError(t, r.close())
+	// then an array
+	var results []queryResult
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &results))
+	assert.Len(t, results, 2)
+	assert.Equal(t, []match{}, results[1].Rows)
+
+	// when no query
+	out.Reset()
+	r = newResultWriter(&out, &diag, FORMAT_JSON)
+	assert.NoError(t, r.close())
+	// then an empty array
+	assert.Equal(t, "[]\n", out.String())
+	assert.Empty(t, diag.String())
+}
+
+func TestResultWriter_NDJSON(t *testing.T) {
+	// given
+	var out, diag bytes.Buffer
+	r := newResultWriter(&out, &diag, FORMAT_NDJSON)
+
+	// when
+	query(r, "age=23", match{Row: "jean,23,M"}, match{Row: "Georgette,23,F"})
+	query(r, "age=24")
+	err := r.close()
+
+	// then a line per match
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	var line struct {
+		Query string `json:"query"`
+		Row   string `json:"row"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &line))
+	assert.Equal(t, "age=23", line.Query)
+	assert.Equal(t, "Georgette,23,F", line.Row)
+	assert.Empty(t, diag.String())
+}
+
+func TestResultWriter_CSV(t *testing.T) {
+	// given
+	var out, diag bytes.Buffer
+	r := newResultWriter(&out, &diag, FORMAT_CSV)
+	key := 3.
+
+	// when
+	query(r, "3", match{Key: &key, Offset: 30, Length: 21, Line: 4, Row: "\"Dupont,\nMarie\",3,F"})
+	err := r.close()
+
+	// then
+	assert.NoError(t, err)
+	records, err := csv.NewReader(&out).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, []string{"query", "key", "offset", "length", "line", "row", "elapsed_ns"}, records[0])
+	assert.Equal(t, []string{"3", "3", "30", "21", "4", "\"Dupont,\nMarie\",3,F"}, records[1][:6])
+	assert.Empty(t, diag.String())
+}
+
+func TestPrintCount(t *testing.T) {
+	c := countResult{Index: "people.age.rmi", Count: 5, Elapsed: 42}
+
+	for format, expected := range map[string]string{
+		FORMAT_TEXT:   "5\n",
+		FORMAT_JSON:   "{\"index\":\"people.age.rmi\",\"count\":5,\"elapsed_ns\":42}\n",
+		FORMAT_NDJSON: "{\"index\":\"people.age.rmi\",\"count\":5,\"elapsed_ns\":42}\n",
+		FORMAT_CSV:    "index,count,elapsed_ns\npeople.age.rmi,5,42\n",
+	} {
+		// when
+		var out bytes.Buffer
+		err := printCount(&out, format, c)
+
+		// then
+		assert.NoError(t, err, format)
+		assert.Equal(t, expected, out.String(), format)
+	}
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/BenJoyenConseil/rmi/bitmap"
+	"github.com/BenJoyenConseil/rmi/catalog"
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/store"
+)
+
+/*
+predicate is a `column=value` or `column!=value` condition of the search command
+*/
+type predicate struct {
+	Column string
+	Key    float64
+	Negate bool
+}
+
+func parsePredicate(s string) (predicate, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return predicate{}, fmt.Errorf("the predicate %q must be written column=value or column!=value", s)
+	}
+	p := predicate{Column: strings.ToLower(s[:i])}
+	if strings.HasSuffix(p.Column, "!") {
+		p.Column, p.Negate = strings.TrimSuffix(p.Column, "!"), true
+	}
+	key, err := strconv.ParseFloat(s[i+1:], 64)
+	if err != nil {
+		return predicate{}, fmt.Errorf("the predicate %q: %s", s, err)
+	}
+	p.Key = key
+	return p, nil
+}
+
+/*
+lookupBitmap returns the bitmap of the row pointers matching the key, empty when the key is not indexed,
+and the source of the index
+*/
+func lookupBitmap(path string, key float64, stale string) (*bitmap.Bitmap, index.Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, index.Source{}, err
+	}
+	defer f.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(f))
+	if err != nil {
+		return nil, index.Source{}, err
+	}
+	if err := checkSource(path, idx.Source, stale); err != nil {
+		return nil, idx.Source, err
+	}
+	b, err := idx.Postings(key)
+	if err != nil {
+		return bitmap.New(), idx.Source, nil
+	}
+	return b, idx.Source, nil
+}
+
+/*
+matchRows returns the pointers of the rows matching all the predicates, or any of them.
+The rows matching a negated predicate are removed from the result in both cases.
+Every column must be indexed from the same table
+*/
+func matchRows(cat *catalog.Catalog, table string, predicates []predicate, any bool, stale string) (source index.Source, pointers []int, err error) {
+	var matched, excluded *bitmap.Bitmap
+	for _, p := range predicates {
+		e, err := cat.Find(table, p.Column)
+		if err != nil {
+			return source, nil, err
+		}
+		if source.Path != "" && e.Source != source.Path {
+			return source, nil, fmt.Errorf("the column %q is indexed from %s, not from %s", p.Column, e.Source, source.Path)
+		}
+		b, src, err := lookupBitmap(cat.Path(e), p.Key, stale)
+		if err != nil {
+			return source, nil, err
+		}
+		source = src
+		switch {
+		case p.Negate && excluded == nil:
+			excluded = b
+		case p.Negate:
+			excluded = bitmap.Or(excluded, b)
+		case matched == nil:
+			matched = b
+		case any:
+			matched = bitmap.Or(matched, b)
+		default:
+			matched = bitmap.And(matched, b)
+		}
+	}
+	if matched == nil {
+		return source, nil, fmt.Errorf("at least one predicate must be column=value")
+	}
+	if excluded != nil {
+		matched = bitmap.AndNot(matched, excluded)
+	}
+	return source, matched.Offsets(), nil
+}
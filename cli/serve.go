@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/BenJoyenConseil/rmi/catalog"
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/server"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func serveIndexes(c *kingpin.ParseContext) error {
+	cat, err := catalog.Open(*serveCatalog)
+	if err != nil {
+		return err
+	}
+	if len(cat.Entries) == 0 {
+		return fmt.Errorf("no index to serve in %s", cat.Dir)
+	}
+	srv, err := server.New(cat, func(path string, source index.Source) error {
+		return checkSource(path, source, *serveStale)
+	})
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+
+	httpServer := &http.Server{Addr: *serveAddr, Handler: srv}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	go func() {
+		<-stop
+		log.Printf("shutting down")
+		httpServer.Shutdown(context.Background())
+	}()
+	log.Printf("serving %d indexes of %s on %s", len(cat.Entries), cat.Dir, *serveAddr)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
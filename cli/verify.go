@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/search"
+	"github.com/BenJoyenConseil/rmi/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func verifyIndex(c *kingpin.ParseContext) error {
+	path, err := resolveIndex(*verifyIndexFile, *verifyCatalog, *verifyTable, *verifyColumn)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	idx, err := index.OpenDisk(store.NewFileBackend(f))
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	fmt.Fprintf(stdout, "%s: %d records, %s layout, error bounds [%d, %d]\n", path, idx.Count(), idx.S.Layout(), idx.MinErrBound, idx.MaxErrBound)
+	if !idx.Checksummed {
+		fmt.Fprintln(stdout, "the index was written without checksums, only its keys are verified")
+	}
+	problems := idx.Verify()
+	if *verifyCSV && len(problems) == 0 {
+		problems = verifySource(idx)
+	}
+	for _, p := range problems {
+		fmt.Fprintln(stdout, "  -", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %d problems found", path, len(problems))
+	}
+	fmt.Fprintln(stdout, "ok")
+	return nil
+}
+
+/*
+verifySource parses the column of the source CSV again and compares, for every key,
+the rows found by search.FullScanLookup with the ones found by the index
+*/
+func verifySource(idx *index.DiskIndex) (problems []error) {
+	if idx.Source.Path == "" {
+		return []error{fmt.Errorf("the index records no source CSV to compare with")}
+	}
+	status, err := idx.Source.Status()
+	if err != nil {
+		return []error{err}
+	}
+	if status.Stale() {
+		return []error{fmt.Errorf("the source %s has changed (%s) since the index was built, refresh or rebuild it", idx.Source.Path, status)}
+	}
+	format := idx.Source.Format
+	column, _, err := extractColumn(idx.Source.Path, idx.Source.Column, &format, math.MaxInt64)
+	if err != nil {
+		return []error{err}
+	}
+	keys, pointers := column.Keys, column.Pointers
+	if len(keys) != idx.Count() {
+		problems = append(problems, fmt.Errorf("%s has %d rows but the index holds %d records", idx.Source.Path, len(keys), idx.Count()))
+	}
+	st := search.NewSortedTable(append([]float64(nil), keys...))
+	for i, key := range st.Keys {
+		if i > 0 && st.Keys[i-1] == key {
+			continue
+		}
+		rows, _ := search.FullScanLookup(key, st)
+		expected := make([]uint64, len(rows))
+		for j, row := range rows {
+			expected[j] = uint64(row)
+			if idx.Values == index.POINTERS {
+				expected[j] = uint64(pointers[row])
+			}
+		}
+		sort.Slice(expected, func(a, b int) bool { return expected[a] < expected[b] })
+		var found []uint64
+		if b, err := idx.Postings(key); err == nil {
+			found = b.ToArray()
+		}
+		if !equalValues(expected, found) {
+			problems = append(problems, fmt.Errorf("the key %v matches %d rows of %s but the index finds %d", key, len(expected), idx.Source.Path, len(found)))
+		}
+	}
+	fmt.Fprintf(stdout, "%d rows of %s compared with a full scan\n", len(keys), idx.Source.Path)
+	return problems
+}
+
+func equalValues(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package linear
 
 import (
+	"encoding/binary"
+	"fmt"
 	"math"
 
 	"gonum.org/v1/gonum/stat"
@@ -45,3 +47,25 @@ func Cdf(x []float64) (sortedX, y []float64) {
 	}
 	return x, y
 }
+
+/*
+MarshalBinary encodes the intercept and the slope as two little endian float64
+*/
+func (m *RegressionModel) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b[:8], math.Float64bits(m.Intercept))
+	binary.LittleEndian.PutUint64(b[8:], math.Float64bits(m.Slope))
+	return b, nil
+}
+
+/*
+UnmarshalBinary decodes a model written by MarshalBinary
+*/
+func (m *RegressionModel) UnmarshalBinary(b []byte) error {
+	if len(b) != 16 {
+		return fmt.Errorf("a linear model is encoded on 16 bytes, got %d", len(b))
+	}
+	m.Intercept = math.Float64frombits(binary.LittleEndian.Uint64(b[:8]))
+	m.Slope = math.Float64frombits(binary.LittleEndian.Uint64(b[8:]))
+	return nil
+}
@@ -92,3 +92,20 @@ func ExampleCdf() {
 	// x: 5 y: 0.8571428571428571
 	// x: 10 y: 1
 }
+
+func TestMarshalBinary(t *testing.T) {
+	// given
+	m := &RegressionModel{Intercept: 0.23119036646681634, Slope: 0.08523040437506509}
+
+	// when
+	b, err := m.MarshalBinary()
+	decoded := &RegressionModel{}
+	errDecode := decoded.UnmarshalBinary(b)
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, errDecode)
+	assert.Len(t, b, 16)
+	assert.Equal(t, m, decoded)
+	assert.Error(t, decoded.UnmarshalBinary(b[:8]))
+}
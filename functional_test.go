@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"math"
 	"math/rand"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/BenJoyenConseil/rmi/index"
 	"github.com/BenJoyenConseil/rmi/search"
+	"github.com/BenJoyenConseil/rmi/store"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,6 +38,60 @@ func TestIsoFunctional(t *testing.T) {
 	}
 }
 
+func TestIsoFunctional_PostingIndex(t *testing.T) {
+
+	// given the titanic.csv dataset
+	ageCol := extractColumn("./data/titanic.csv", "age")
+	pi := index.NewPosting(ageCol)
+	st := search.NewSortedTable(ageCol)
+
+	// when Lookup using full scan and posting lists
+	for i := 0.; i <= 100; i++ {
+
+		resultFS, errFS := search.FullScanLookup(i, st)
+		resultPI, errPI := pi.Lookup(i)
+
+		// then forearch key result should be the same, in ascending row order
+		assert.Equal(t, resultFS, resultPI, i)
+		assert.Equal(t, errFS, errPI, i)
+		assert.True(t, sort.IntsAreSorted(resultPI), i)
+	}
+}
+
+func TestIsoFunctional_DiskIndex(t *testing.T) {
+	for _, layout := range []store.Layout{store.INTERLEAVED, store.COLUMNAR, store.COMPRESSED} {
+		t.Run(layout.String(), func(t *testing.T) { testIsoFunctionalDiskIndex(t, layout) })
+	}
+}
+
+func testIsoFunctionalDiskIndex(t *testing.T, layout store.Layout) {
+
+	// given the titanic.csv dataset flushed inside a store file
+	ageCol := extractColumn("./data/titanic.csv", "age")
+	li := index.New(ageCol)
+	f, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	index.FlushWith(li, store.NewFileBackend(f), store.Options{Layout: layout, PageSize: store.PAGE_SIZE})
+	di, err := index.OpenDisk(store.NewFileBackend(f))
+	assert.NoError(t, err)
+	mi, err := index.OpenMmap(f.Name())
+	assert.NoError(t, err)
+	defer mi.Close()
+
+	// when Lookup in memory, on disk and in the mapped file
+	for i := 0.; i <= 100; i++ {
+
+		resultLI, errLI := li.Lookup(i)
+		resultDI, errDI := di.Lookup(i)
+		resultMI, errMI := mi.Lookup(i)
+
+		// then forearch key result should be the same
+		assert.Equal(t, resultLI, resultDI, i)
+		assert.Equal(t, errLI, errDI, i)
+		assert.Equal(t, resultLI, resultMI, i)
+		assert.Equal(t, errLI, errMI, i)
+	}
+}
+
 var min, max = 0., 100.
 var random = func() float64 { return math.Round(min + rand.Float64()*(max-min)) }
 
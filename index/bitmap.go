@@ -0,0 +1,29 @@
+package index
+
+import (
+	"fmt"
+
+	"github.com/BenJoyenConseil/rmi/bitmap"
+)
+
+/*
+Postings return the bitmap of the values of all the records holding the key.
+The error bounds only cover the last of the duplicated keys, so the window is widened
+while the keys at its edges are still equal to the key
+*/
+func (idx *DiskIndex) Postings(key float64) (*bitmap.Bitmap, error) {
+	b := bitmap.FromOffsets(idx.delta.lookup(key))
+	if idx.Len > 0 {
+		lo, hi := idx.window(key)
+		keys := idx.S.GetKeys(lo, hi)
+		if i, j := equalRange(len(keys), key, func(i int) float64 { return keys[i] }); i < j {
+			for _, v := range idx.S.GetValues(lo+int64(i), lo+int64(j-1)) {
+				b.Add(v)
+			}
+		}
+	}
+	if b.Cardinality() == 0 {
+		return nil, fmt.Errorf("The following key <%f> is not found in the index", key)
+	}
+	return b, nil
+}
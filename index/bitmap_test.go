@@ -0,0 +1,38 @@
+package index
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskPostings(t *testing.T) {
+	for _, layout := range []store.Layout{store.INTERLEAVED, store.COLUMNAR, store.COMPRESSED} {
+		// given many duplicates, most of them outside of the error bounds
+		keys := make([]float64, 1000)
+		for i := range keys {
+			keys[i] = float64(i % 3)
+		}
+		keys[999] = 7
+		file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+		f := store.NewFileBackend(file)
+		FlushWith(New(keys), f, store.Options{Layout: layout})
+		disk, _ := OpenDisk(f)
+
+		// when
+		ones, err := disk.Postings(1)
+		seven, _ := disk.Postings(7)
+		missing, errMissing := disk.Postings(2.5)
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, 333, ones.Cardinality(), layout)
+		assert.True(t, ones.Contains(1))
+		assert.True(t, ones.Contains(997))
+		assert.Equal(t, []int{999}, seven.Offsets())
+		assert.Error(t, errMissing)
+		assert.Nil(t, missing)
+	}
+}
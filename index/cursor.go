@@ -0,0 +1,101 @@
+package index
+
+import (
+	"sort"
+)
+
+/*
+Cursor looks up keys given in ascending order, like the merge step of a sort-merge join: each key is searched
+forward from the position of the previous one, probing positions at doubling distances, instead of asking the
+model. A key lower than the previous one is located with the model again
+*/
+type Cursor struct {
+	idx     *DiskIndex
+	key     float64
+	pos     int // first position of the store whose key is >= key
+	dpos    int // first position of the delta whose key is >= key
+	started bool
+	sorted  bool
+}
+
+/*
+Cursor returns a cursor before the first key of the index
+*/
+func (idx *DiskIndex) Cursor() *Cursor {
+	return &Cursor{idx: idx, sorted: true}
+}
+
+/*
+Seek moves the cursor to the key and returns the values of the records holding it, the ones of the store first.
+Sorted returns false once Seek has been called with a key lower than the previous one
+*/
+func (c *Cursor) Seek(key float64) (values []uint64) {
+	if key != key {
+		// NaN is never indexed, and would break the order of the keys
+		return nil
+	}
+	idx, d := c.idx, c.idx.delta
+	if !c.started || key < c.key {
+		c.pos, c.dpos = 0, 0
+		if idx.Len > 0 {
+			c.pos = idx.lowerBound(key, func(k float64) bool { return k >= key })
+		}
+		c.sorted = c.sorted && !c.started
+		c.started = true
+	} else {
+		c.pos = c.gallop(key)
+	}
+	c.key = key
+	c.dpos += sort.Search(len(d.Keys)-c.dpos, func(i int) bool { return d.Keys[c.dpos+i] >= key })
+
+	for pos := c.pos; pos < idx.Len; pos += RANGE_CHUNK {
+		end := pos + RANGE_CHUNK - 1
+		if end > idx.Len-1 {
+			end = idx.Len - 1
+		}
+		keys := idx.S.GetKeys(int64(pos), int64(end))
+		n := 0
+		for n < len(keys) && keys[n] == key {
+			n++
+		}
+		if n > 0 {
+			values = append(values, idx.S.GetValues(int64(pos), int64(pos+n-1))...)
+		}
+		if n < len(keys) {
+			break
+		}
+	}
+	for i := c.dpos; i < len(d.Keys) && d.Keys[i] == key; i++ {
+		values = append(values, d.Values[i])
+	}
+	return values
+}
+
+/*
+Sorted tells if the keys have been sought in ascending order so far
+*/
+func (c *Cursor) Sorted() bool {
+	return c.sorted
+}
+
+// gallop returns the first position from c.pos whose key is >= key
+func (c *Cursor) gallop(key float64) int {
+	s, last := c.idx.S, int64(c.idx.Len-1)
+	lo := int64(c.pos)
+	if lo > last || s.GetKeys(lo, lo)[0] >= key {
+		return c.pos
+	}
+	// the key of lo is < key, the first key >= key is after lo and up to hi
+	hi := lo + 1
+	for step := int64(2); hi <= last && s.GetKeys(hi, hi)[0] < key; step *= 2 {
+		lo, hi = hi, hi+step
+	}
+	if hi > last {
+		hi = last
+	}
+	if lo == hi {
+		return int(lo) + 1
+	}
+	keys := s.GetKeys(lo+1, hi)
+	return int(lo) + 1 + sort.Search(len(keys), func(i int) bool { return keys[i] >= key })
+}
@@ -0,0 +1,68 @@
+package index
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor_Seek(t *testing.T) {
+	// given runs of duplicates longer than the error window, and a delta
+	keys := make([]float64, 3000)
+	for i := range keys {
+		keys[i] = float64(rand.Intn(200))
+	}
+	mem := store.NewMemBackend(nil)
+	FlushWith(New(keys), mem, store.Options{Layout: store.COLUMNAR})
+	disk, _ := OpenDisk(mem)
+	disk.Insert([]float64{41.5, 42, 42, 250}, []uint64{3000, 3001, 3002, 3003}, Source{}, store.Options{})
+	expected := func(k float64) []uint64 {
+		b, err := disk.Postings(k)
+		if err != nil {
+			return nil
+		}
+		return b.ToArray()
+	}
+	cursor := disk.Cursor()
+
+	for k := -1.; k <= 251; k += .5 {
+		// when
+		values := cursor.Seek(k)
+		again := cursor.Seek(k)
+
+		// then
+		assert.ElementsMatch(t, expected(k), values, k)
+		assert.Equal(t, values, again, k)
+	}
+	assert.True(t, cursor.Sorted())
+
+	// when the keys are not sorted
+	for _, k := range []float64{42, 3, 150, 41.5} {
+		values := cursor.Seek(k)
+
+		// then
+		assert.ElementsMatch(t, expected(k), values, k)
+	}
+	assert.False(t, cursor.Sorted())
+}
+
+func TestCursor_Gallop(t *testing.T) {
+	// given
+	keys := []float64{1, 2, 3, 5, 8, 13, 21, 34, 55, 89}
+	mem := store.NewMemBackend(nil)
+	Flush(New(keys), mem)
+	disk, _ := OpenDisk(mem)
+	cursor := disk.Cursor()
+	cursor.Seek(1)
+
+	for _, k := range []float64{1, 4, 5, 34, 35, 89, 90} {
+		// when
+		pos := cursor.gallop(k)
+
+		// then
+		assert.Equal(t, sort.SearchFloat64s(keys, k), pos, k)
+	}
+}
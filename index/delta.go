@@ -0,0 +1,219 @@
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BenJoyenConseil/rmi/store"
+)
+
+const (
+	DELTA_RATIO = 8 // the delta is merged into the records once it holds more than 1/DELTA_RATIO of them
+)
+
+/*
+delta holds the records inserted since the model was fitted, sorted by key.
+It is searched with a binary search next to the records located by the model
+*/
+type delta struct {
+	Keys   []float64
+	Values []uint64
+}
+
+func readDelta(r io.ReaderAt, off int64, n int) (d delta, err error) {
+	if n == 0 {
+		return d, nil
+	}
+	b := make([]byte, int64(n)*store.RECORD_LEN)
+	if _, err := r.ReadAt(b, off); err != nil {
+		return d, fmt.Errorf("the delta of %d records is truncated: %s", n, err)
+	}
+	d.Keys, d.Values = make([]float64, n), make([]uint64, n)
+	for i := range d.Keys {
+		d.Keys[i], d.Values[i] = store.FromRecord(b[int64(i)*store.RECORD_LEN:])
+	}
+	return d, nil
+}
+
+func (d delta) bytes() []byte {
+	b := make([]byte, int64(len(d.Keys))*store.RECORD_LEN)
+	for i := range d.Keys {
+		off := int64(i) * store.RECORD_LEN
+		binary.LittleEndian.PutUint64(b[off:], math.Float64bits(d.Keys[i]))
+		binary.LittleEndian.PutUint64(b[off+store.KEY_LEN:], d.Values[i])
+	}
+	return b
+}
+
+func (d delta) lookup(key float64) (offsets []int) {
+	i, j := equalRange(len(d.Keys), key, func(i int) float64 { return d.Keys[i] })
+	for ; i < j; i++ {
+		offsets = append(offsets, int(d.Values[i]))
+	}
+	return offsets
+}
+
+// merge returns the records of d and of the sorted keys and values, the ones of d first for equal keys
+func (d delta) merge(keys []float64, values []uint64) delta {
+	m := delta{Keys: make([]float64, 0, len(d.Keys)+len(keys)), Values: make([]uint64, 0, len(d.Keys)+len(keys))}
+	i, j := 0, 0
+	for i < len(d.Keys) || j < len(keys) {
+		if j == len(keys) || (i < len(d.Keys) && d.Keys[i] <= keys[j]) {
+			m.Keys, m.Values = append(m.Keys, d.Keys[i]), append(m.Values, d.Values[i])
+			i++
+		} else {
+			m.Keys, m.Values = append(m.Keys, keys[j]), append(m.Values, values[j])
+			j++
+		}
+	}
+	return m
+}
+
+// sortedDelta sorts the keys and their values, keeping the order of the values of equal keys
+func sortedDelta(keys []float64, values []uint64) delta {
+	d := delta{Keys: append([]float64(nil), keys...), Values: append([]uint64(nil), values...)}
+	sort.Stable(d)
+	return d
+}
+
+func (d delta) Len() int           { return len(d.Keys) }
+func (d delta) Less(i, j int) bool { return d.Keys[i] < d.Keys[j] }
+func (d delta) Swap(i, j int) {
+	d.Keys[i], d.Keys[j] = d.Keys[j], d.Keys[i]
+	d.Values[i], d.Values[j] = d.Values[j], d.Values[i]
+}
+
+/*
+Count returns the number of records of the index, the ones of the delta included
+*/
+func (idx *DiskIndex) Count() int {
+	return idx.Len + idx.DeltaLen
+}
+
+/*
+Insert adds the keys and their values to the index and records the new state of its source.
+The new records are merged into the delta, written after the records of the store,
+then the meta section is rewritten in place to point at it.
+Once the delta holds more than 1/DELTA_RATIO of the records, or when the meta section can't be rewritten
+in place, the whole store is rewritten with the model fitted over all the keys: it returns retrained = true.
+opts.PageSize is used by this rewrite, the layout of the store is kept
+*/
+func (idx *DiskIndex) Insert(keys []float64, values []uint64, source Source, opts store.Options) (retrained bool, err error) {
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("%d values for %d keys", len(values), len(keys))
+	}
+	inserted := sortedDelta(keys, values)
+	d := idx.delta.merge(inserted.Keys, inserted.Values)
+	meta := idx.Meta
+	meta.Source, meta.DeltaLen = source, len(d.Keys)
+	meta.DeltaChecksum = store.Checksum(d.bytes())
+
+	old, err := idx.S.Meta()
+	if err != nil {
+		return false, err
+	}
+	b, err := encodeMeta(meta)
+	if err != nil {
+		return false, err
+	}
+	if len(d.Keys)*DELTA_RATIO > idx.Len || len(b) != len(old) {
+		return true, idx.retrain(d, meta, opts)
+	}
+
+	// the new delta is written after the previous one, which stays valid until the meta section is rewritten
+	meta.DeltaOffset, err = idx.S.Size()
+	if err != nil {
+		return false, err
+	}
+	if meta.DeltaOffset%store.KEY_LEN != 0 {
+		meta.DeltaOffset += store.KEY_LEN - meta.DeltaOffset%store.KEY_LEN
+	}
+	if _, err := idx.S.WriteAt(d.bytes(), meta.DeltaOffset); err != nil {
+		return false, err
+	}
+	if err := idx.S.Sync(); err != nil {
+		return false, err
+	}
+	if b, err = encodeMeta(meta); err != nil {
+		return false, err
+	}
+	if err := idx.S.SetMeta(b); err != nil {
+		return false, err
+	}
+	idx.Meta, idx.delta = meta, d
+	return false, nil
+}
+
+/*
+retrain rewrites the store with the records and the delta merged, and the model fitted over all the keys.
+A store file is rewritten through a temporary file renamed over it, the other backends in place
+*/
+func (idx *DiskIndex) retrain(d delta, meta Meta, opts store.Options) error {
+	all := delta{}
+	if idx.Len > 0 {
+		all.Keys, all.Values = idx.S.GetKeys(0, int64(idx.Len-1)), idx.S.GetValues(0, int64(idx.Len-1))
+	}
+	all = all.merge(d.Keys, d.Values)
+	meta.M, meta.MinErrBound, meta.MaxErrBound = fit(all.Keys)
+	meta.Len, meta.DeltaOffset, meta.DeltaLen = len(all.Keys), 0, 0
+	opts.Layout = idx.S.Layout()
+	var s store.Store
+	var err error
+	if f, ok := idx.S.Backend.(*store.FileBackend); ok {
+		s, err = replace(f, &meta, opts, all.Keys, all.Values)
+	} else {
+		s, err = write(idx.S.Backend, &meta, opts, all.Keys, all.Values)
+	}
+	if err != nil {
+		return err
+	}
+	idx.Meta, idx.S, idx.delta = meta, s, delta{}
+	return nil
+}
+
+/*
+replace writes the store to a temporary file next to the file of f, syncs it and renames it over that file,
+so that a crash or a failed write leaves the previous store intact. f is then switched to the renamed file,
+reopened under its path so that the next retrain replaces the same file
+*/
+func replace(f *store.FileBackend, meta *Meta, opts store.Options, keys []float64, values []uint64) (store.Store, error) {
+	path := f.Name()
+	fi, err := f.Stat()
+	if err != nil {
+		return store.Store{}, err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return store.Store{}, err
+	}
+	s, err := write(store.NewFileBackend(tmp), meta, opts, keys, values)
+	if err == nil {
+		err = tmp.Chmod(fi.Mode())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return store.Store{}, err
+	}
+	tmp.Close()
+	renamed, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return store.Store{}, err
+	}
+	f.File.Close()
+	f.File = renamed
+	s.Backend = f
+	return s, nil
+}
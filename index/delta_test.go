@@ -0,0 +1,143 @@
+package index
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsert_Delta(t *testing.T) {
+	for _, layout := range []store.Layout{store.INTERLEAVED, store.COLUMNAR, store.COMPRESSED} {
+		// given
+		keys := make([]float64, 100)
+		for i := range keys {
+			keys[i] = float64(i % 10)
+		}
+		file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+		f := store.NewFileBackend(file)
+		FlushWith(New(keys), f, store.Options{Layout: layout})
+		disk, _ := OpenDisk(f)
+		model := disk.M
+
+		// when
+		retrained, err := disk.Insert([]float64{3, 42, 3}, []uint64{100, 101, 102}, Source{}, store.Options{})
+
+		// then
+		assert.NoError(t, err)
+		assert.False(t, retrained)
+		assert.Equal(t, model, disk.M)
+		assert.Equal(t, 103, disk.Count())
+		offsets, _ := disk.Lookup(42)
+		assert.Equal(t, []int{101}, offsets)
+
+		// when the index is opened again
+		reopened, err := OpenDisk(f)
+		mmapped, errMmap := OpenMmap(f.Name())
+
+		// then
+		assert.NoError(t, err)
+		assert.NoError(t, errMmap)
+		assert.Equal(t, 3, reopened.DeltaLen)
+		offsets, _ = reopened.Lookup(42)
+		assert.Equal(t, []int{101}, offsets)
+		offsets, _ = mmapped.Lookup(42)
+		assert.Equal(t, []int{101}, offsets)
+		threes, _ := reopened.Postings(3)
+		assert.Equal(t, []int{3, 13, 23, 33, 43, 53, 63, 73, 83, 93, 100, 102}, threes.Offsets())
+		mmapped.Close()
+
+		// when the delta is merged
+		retrained, err = reopened.Insert(make([]float64, 10), make([]uint64, 10), Source{}, store.Options{})
+
+		// then
+		assert.NoError(t, err)
+		assert.True(t, retrained)
+		assert.Equal(t, 113, reopened.Len)
+		assert.Equal(t, 0, reopened.DeltaLen)
+		assert.Equal(t, layout, reopened.S.Layout())
+		offsets, _ = reopened.Lookup(42)
+		assert.Equal(t, []int{101}, offsets)
+		merged, _ := OpenDisk(f)
+		assert.Equal(t, 113, merged.Count())
+		offsets, _ = merged.Lookup(42)
+		assert.Equal(t, []int{101}, offsets)
+	}
+}
+
+func TestInsert_RetrainReplacesFile(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	path := filepath.Join(dir, "people.age.rmi")
+	file, _ := os.Create(path)
+	file.Chmod(0640)
+	f := store.NewFileBackend(file)
+	FlushWith(New([]float64{1, 2, 3, 4, 5, 6, 7, 8}), f, store.Options{})
+	disk, _ := OpenDisk(f)
+	before, _ := os.Open(path)
+	defer before.Close()
+
+	// when
+	retrained, err := disk.Insert([]float64{9, 10}, []uint64{8, 9}, Source{}, store.Options{})
+
+	// then the store is written to a new file renamed over the previous one, left untouched
+	assert.NoError(t, err)
+	assert.True(t, retrained)
+	previous, _ := OpenDisk(store.NewFileBackend(before))
+	assert.Equal(t, 8, previous.Count())
+	reopened, _ := os.Open(path)
+	defer reopened.Close()
+	replaced, err := OpenDisk(store.NewFileBackend(reopened))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, replaced.Len)
+	assert.Empty(t, replaced.Verify())
+	entries, _ := ioutil.ReadDir(dir)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, os.FileMode(0640), entries[0].Mode())
+
+	// when the index is retrained again through the same backend
+	retrained, err = disk.Insert([]float64{11, 12}, []uint64{10, 11}, Source{}, store.Options{})
+	// then the same file is replaced
+	assert.NoError(t, err)
+	assert.True(t, retrained)
+	assert.Equal(t, f, disk.S.Backend)
+	assert.Equal(t, path, f.Name())
+	again, _ := os.Open(path)
+	defer again.Close()
+	replaced, err = OpenDisk(store.NewFileBackend(again))
+	assert.NoError(t, err)
+	assert.Equal(t, 12, replaced.Len)
+	entries, _ = ioutil.ReadDir(dir)
+	assert.Len(t, entries, 1)
+	assert.NoError(t, f.Close())
+}
+
+func TestInsert_Errors(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+	FlushWith(New([]float64{1, 2, 3}), f, store.Options{})
+	disk, _ := OpenDisk(f)
+
+	// when
+	_, err := disk.Insert([]float64{1}, []uint64{}, Source{}, store.Options{})
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestDeltaMerge(t *testing.T) {
+	// given
+	d := sortedDelta([]float64{3, 1, 3}, []uint64{0, 1, 2})
+
+	// when
+	m := d.merge([]float64{1, 3, 4}, []uint64{3, 4, 5})
+
+	// then
+	assert.Equal(t, []float64{1, 1, 3, 3, 3, 4}, m.Keys)
+	assert.Equal(t, []uint64{1, 3, 0, 2, 4, 5}, m.Values)
+	assert.Equal(t, []int{0, 2, 4}, m.lookup(3))
+}
@@ -0,0 +1,201 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/BenJoyenConseil/rmi/search"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
+)
+
+/*
+DiskIndex is a LearnedIndex whose sorted table stays inside a store file.
+Only the model and its error bounds are kept in memory
+*/
+type DiskIndex struct {
+	Meta
+	S     store.Store
+	delta delta
+}
+
+/*
+Flush writes the model of the index inside the meta section of f, followed by its sorted table
+*/
+func Flush(idx *LearnedIndex, f store.Backend) (store.Store, error) {
+	return FlushPaged(idx, f, 0)
+}
+
+/*
+FlushPaged works like Flush but aligns the records on pages of pageSize bytes,
+so that a BufferPool fetches only the pages overlapping the error window of a lookup
+*/
+func FlushPaged(idx *LearnedIndex, f store.Backend, pageSize int64) (store.Store, error) {
+	return FlushWith(idx, f, store.Options{PageSize: pageSize})
+}
+
+/*
+FlushWith writes the index with the page size and the layout of opts
+*/
+func FlushWith(idx *LearnedIndex, f store.Backend, opts store.Options) (store.Store, error) {
+	return flush(idx, f, opts, ROWS, Source{}, func(offset int) uint64 { return uint64(offset) })
+}
+
+/*
+FlushPointers works like FlushWith, but instead of the row positions it writes the pointers to the rows
+inside the source file, pointers[i] locating the row i. Readers can then seek straight to the matching rows
+*/
+func FlushPointers(idx *LearnedIndex, f store.Backend, opts store.Options, source Source, pointers []table.Pointer) (store.Store, error) {
+	if len(pointers) != idx.Len {
+		return store.Store{}, fmt.Errorf("%d pointers for %d keys", len(pointers), idx.Len)
+	}
+	return flush(idx, f, opts, POINTERS, source, func(offset int) uint64 { return uint64(pointers[offset]) })
+}
+
+func flush(idx *LearnedIndex, f store.Backend, opts store.Options, kind ValueKind, source Source, value func(offset int) uint64) (store.Store, error) {
+	meta := Meta{
+		M:           idx.M,
+		Len:         idx.Len,
+		MinErrBound: idx.MinErrBound,
+		MaxErrBound: idx.MaxErrBound,
+		Values:      kind,
+		Source:      source,
+	}
+	keys, values := make([]float64, idx.Len), make([]uint64, idx.Len)
+	for i := 0; i < idx.Len; i++ {
+		keys[i], values[i] = idx.ST.Keys[i], value(idx.ST.Offsets[i])
+	}
+	return write(f, &meta, opts, keys, values)
+}
+
+// write writes the records to f, then rewrites the meta section in place with their checksum
+func write(f store.Backend, meta *Meta, opts store.Options, keys []float64, values []uint64) (store.Store, error) {
+	meta.Checksummed, meta.Checksum, meta.DeltaChecksum = true, 0, 0
+	b, err := encodeMeta(*meta)
+	if err != nil {
+		return store.Store{}, err
+	}
+	s, err := store.Write(f, b, opts, keys, values)
+	if err != nil {
+		return s, err
+	}
+	if meta.Checksum, err = s.RecordsChecksum(); err != nil {
+		return s, err
+	}
+	if b, err = encodeMeta(*meta); err != nil {
+		return s, err
+	}
+	return s, s.SetMeta(b)
+}
+
+/*
+OpenDisk reads the model from the meta section of f without loading the records
+*/
+func OpenDisk(f store.Backend) (*DiskIndex, error) {
+	s, err := store.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := s.Meta()
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, fmt.Errorf("the store has no model, it must be written by index.Flush")
+	}
+	idx := &DiskIndex{S: s}
+	if idx.Meta, err = decodeMeta(meta); err != nil {
+		return idx, err
+	}
+	idx.delta, err = readDelta(f, idx.DeltaOffset, idx.DeltaLen)
+	return idx, err
+}
+
+/*
+Load reads the records of the store and returns them with the model as a LearnedIndex,
+whose offsets are the values of the records. The records of the delta, not fitted by the model, are left out
+*/
+func (idx *DiskIndex) Load() *LearnedIndex {
+	st := &search.SortedTable{}
+	if idx.Len > 0 {
+		st.Keys = idx.S.GetKeys(0, int64(idx.Len-1))
+		for _, v := range idx.S.GetValues(0, int64(idx.Len-1)) {
+			st.Offsets = append(st.Offsets, int(v))
+		}
+	}
+	return &LearnedIndex{M: idx.M, ST: st, Len: idx.Len, MinErrBound: idx.MinErrBound, MaxErrBound: idx.MaxErrBound}
+}
+
+/*
+GuessIndex return the predicted position of the key in the store
+and upper / lower positions' search interval
+*/
+func (idx *DiskIndex) GuessIndex(key float64) (guess, lower, upper int) {
+	return guessIndex(idx.M, idx.Len, idx.MinErrBound, idx.MaxErrBound, key)
+}
+
+// window returns the error window of the key, widened to all the records holding it
+func (idx *DiskIndex) window(key float64) (lower, upper int64) {
+	_, lo, hi := idx.GuessIndex(key)
+	lo, hi = widen(key, lo, hi, idx.Len, func(i int) float64 { return idx.S.GetKeys(int64(i), int64(i))[0] })
+	return int64(lo), int64(hi)
+}
+
+/*
+Lookup reads the records between the error bounds with a single ReadAt
+and searches the key inside this window, widened to all its duplicates.
+With a COLUMNAR store, only the keys of the window are read, then the values of the matching keys.
+With a COMPRESSED store, only the blocks of the window are read and decoded
+*/
+func (idx *DiskIndex) Lookup(key float64) (offsets []int, err error) {
+	if idx.Len > 0 && idx.S.Layout() == store.COLUMNAR {
+		lower, upper := idx.window(key)
+		keys := idx.S.GetKeys(lower, upper)
+		i, j := equalRange(len(keys), key, func(i int) float64 { return keys[i] })
+		if i < j {
+			for _, v := range idx.S.GetValues(lower+int64(i), lower+int64(j-1)) {
+				offsets = append(offsets, int(v))
+			}
+		}
+	} else if idx.Len > 0 {
+		lower, upper := idx.window(key)
+		records := idx.S.GetRange(lower, upper)
+		i, j := equalRange(len(records), key, func(i int) float64 { return records[i].Key() })
+		for ; i < j; i++ {
+			offsets = append(offsets, int(records[i].Value()))
+		}
+	}
+	offsets = append(offsets, idx.delta.lookup(key)...)
+
+	if len(offsets) == 0 {
+		err = fmt.Errorf("The following key <%f> is not found in the index", key)
+	}
+	return offsets, err
+}
+
+/*
+widen extends the window [lower, upper] of the key among the n sorted keys while the keys at its edges
+are still equal to it, doubling the step each time: the error bounds only cover the last of the duplicated keys
+*/
+func widen(key float64, lower, upper, n int, at func(i int) float64) (lo, hi int) {
+	lo, hi = lower, upper
+	for step := hi - lo + 1; lo > 0 && at(lo) == key; step *= 2 {
+		if lo -= step; lo < 0 {
+			lo = 0
+		}
+	}
+	for step := hi - lo + 1; hi < n-1 && at(hi) == key; step *= 2 {
+		if hi += step; hi > n-1 {
+			hi = n - 1
+		}
+	}
+	return lo, hi
+}
+
+// equalRange returns the positions [i, j) of the keys equal to key among the n sorted keys
+func equalRange(n int, key float64, at func(i int) float64) (i, j int) {
+	i = sort.Search(n, func(i int) bool { return at(i) >= key })
+	for j = i; j < n && at(j) == key; j++ {
+	}
+	return i, j
+}
@@ -0,0 +1,298 @@
+package index
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/estimate/linear"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlushAndOpenDisk(t *testing.T) {
+	// given
+	keys := []float64{5, 3, 3, 3.14, 10, 2.5, 2.98}
+	idx := New(keys)
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+
+	// when
+	s, err := Flush(idx, f)
+	disk, errOpen := OpenDisk(f)
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, errOpen)
+	assert.Equal(t, int64(7), s.RecordCount())
+	assert.Equal(t, 7, disk.Len)
+	assert.Equal(t, idx.M, disk.M)
+	assert.Equal(t, 2, disk.MaxErrBound)
+	assert.Equal(t, -2, disk.MinErrBound)
+}
+
+func TestOpenDisk_WithoutMeta(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+	f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+
+	// when
+	_, err := OpenDisk(f)
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestDiskLookup(t *testing.T) {
+	// given
+	idx := New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98})
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+	Flush(idx, f)
+	disk, _ := OpenDisk(f)
+
+	// when
+	offsets, err := disk.Lookup(3.)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, offsets)
+	// when
+	offsets, err = disk.Lookup(10.)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []int{4}, offsets)
+	// when not in the index
+	offsets, err = disk.Lookup(199.)
+	// then
+	assert.Error(t, err)
+	assert.Nil(t, offsets)
+}
+
+func TestDiskLookup_Duplicates(t *testing.T) {
+	// given runs of duplicates longer than the error window
+	keys := make([]float64, 1000)
+	for i := range keys {
+		keys[i] = float64(i % 3)
+	}
+	for _, layout := range []store.Layout{store.INTERLEAVED, store.COLUMNAR, store.COMPRESSED} {
+		mem := store.NewMemBackend(nil)
+		FlushWith(New(append([]float64{}, keys...)), mem, store.Options{Layout: layout})
+		disk, _ := OpenDisk(mem)
+		same := store.NewMemBackend(nil)
+		FlushWith(New([]float64{5, 5, 5}), same, store.Options{Layout: layout})
+		fives, _ := OpenDisk(same)
+
+		// when
+		zeros, err := disk.Lookup(0)
+		twos, _ := disk.Lookup(2)
+		all, _ := fives.Lookup(5)
+
+		// then
+		assert.NoError(t, err, layout)
+		assert.Len(t, zeros, 334, layout)
+		assert.Len(t, twos, 333, layout)
+		assert.ElementsMatch(t, []int{0, 1, 2}, all, layout)
+	}
+}
+
+func TestDiskLookup_Empty(t *testing.T) {
+	// given
+	idx := &LearnedIndex{M: &linear.RegressionModel{}}
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+	Flush(idx, f)
+	disk, _ := OpenDisk(f)
+
+	// when
+	offsets, err := disk.Lookup(1.)
+
+	// then
+	assert.Error(t, err)
+	assert.Nil(t, offsets)
+}
+
+func TestDiskLookup_WithPool(t *testing.T) {
+	// given 4 records per page
+	idx := New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98})
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+	FlushPaged(idx, f, 64)
+	disk, _ := OpenDisk(f)
+	pool := store.NewBufferPool(f, 64, 8)
+	disk.S = disk.S.WithPool(pool)
+
+	// when
+	offsets, err := disk.Lookup(3.)
+	first := pool.Stats()
+	offsetsAgain, _ := disk.Lookup(3.)
+
+	// then the second lookup only reads pages of the pool
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, offsets)
+	assert.Equal(t, offsets, offsetsAgain)
+	stats := pool.Stats()
+	assert.Equal(t, first.Misses, stats.Misses)
+	assert.Greater(t, stats.Hits, first.Hits)
+}
+
+func TestOpenMmap(t *testing.T) {
+	// given
+	idx := New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98})
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+	Flush(idx, f)
+
+	// when
+	mmapped, err := OpenMmap(f.Name())
+
+	// then
+	assert.NoError(t, err)
+	defer mmapped.Close()
+	assert.Equal(t, idx.M, mmapped.M)
+	assert.Equal(t, 7, mmapped.Len)
+	offsets, err := mmapped.Lookup(3.)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, offsets)
+	offsets, err = mmapped.Lookup(2.5)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5}, offsets)
+	offsets, err = mmapped.Lookup(199.)
+	assert.Error(t, err)
+	assert.Nil(t, offsets)
+}
+
+func TestOpenMmap_Duplicates(t *testing.T) {
+	// given runs of duplicates longer than the error window
+	keys := make([]float64, 1000)
+	for i := range keys {
+		keys[i] = float64(i % 3)
+	}
+	for _, layout := range []store.Layout{store.INTERLEAVED, store.COLUMNAR, store.COMPRESSED} {
+		file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+		FlushWith(New(append([]float64{}, keys...)), store.NewFileBackend(file), store.Options{Layout: layout})
+		mmapped, err := OpenMmap(file.Name())
+		assert.NoError(t, err, layout)
+
+		// when
+		zeros, err := mmapped.Lookup(0)
+		twos, _ := mmapped.Lookup(2)
+
+		// then
+		assert.NoError(t, err, layout)
+		assert.Len(t, zeros, 334, layout)
+		assert.Len(t, twos, 333, layout)
+		mmapped.Close()
+	}
+}
+
+func TestOpenMmap_WithoutMeta(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+	f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+
+	// when
+	_, err := OpenMmap(f.Name())
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestDiskLookup_Columnar(t *testing.T) {
+	testDiskLookupLayout(t, store.COLUMNAR)
+}
+
+func TestDiskLookup_Compressed(t *testing.T) {
+	testDiskLookupLayout(t, store.COMPRESSED)
+}
+
+func testDiskLookupLayout(t *testing.T, layout store.Layout) {
+	// given
+	idx := New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98})
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+	FlushWith(idx, f, store.Options{Layout: layout})
+	disk, _ := OpenDisk(f)
+	mmapped, _ := OpenMmap(f.Name())
+	defer mmapped.Close()
+
+	for _, k := range []float64{2.5, 2.98, 3, 3.14, 5, 10, 199} {
+		// when
+		offsetsLI, errLI := idx.Lookup(k)
+		offsetsDI, errDI := disk.Lookup(k)
+		offsetsMI, errMI := mmapped.Lookup(k)
+
+		// then
+		assert.Equal(t, layout, disk.S.Layout())
+		assert.Equal(t, offsetsLI, offsetsDI, k)
+		assert.Equal(t, errLI, errDI, k)
+		assert.Equal(t, offsetsLI, offsetsMI, k)
+		assert.Equal(t, errLI, errMI, k)
+	}
+}
+
+func TestFlushPointers(t *testing.T) {
+	// given the rows of people.csv, "name,age\n" excluded
+	idx := New([]float64{90, 23, 3})
+	pointers := []table.Pointer{9<<24 | 9, 19<<24 | 7, 27<<24 | 8}
+	file, _ := ioutil.TempFile(t.TempDir(), "*.rmi")
+	f := store.NewFileBackend(file)
+
+	// when
+	_, err := FlushPointers(idx, f, store.Options{}, Source{Path: "data/people.csv", Column: "age"}, pointers)
+	disk, _ := OpenDisk(f)
+	offsets, _ := disk.Lookup(23)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, POINTERS, disk.Values)
+	assert.Equal(t, Source{Path: "data/people.csv", Column: "age"}, disk.Source)
+	assert.Equal(t, []int{19<<24 | 7}, offsets)
+
+	// when the pointers don't match the keys
+	_, err = FlushPointers(idx, f, store.Options{}, Source{}, pointers[:1])
+	// then
+	assert.Error(t, err)
+}
+
+func TestDiskIndex_MemBackend(t *testing.T) {
+	// given an index flushed in memory
+	idx := New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98})
+	mem := store.NewMemBackend(nil)
+	_, err := FlushWith(idx, mem, store.Options{Layout: store.COMPRESSED})
+	assert.NoError(t, err)
+
+	// when opened over a read-only io.ReaderAt
+	data := mem.Bytes()
+	disk, err := OpenDisk(store.NewReaderAtBackend(bytes.NewReader(data), int64(len(data))))
+
+	// then
+	assert.NoError(t, err)
+	for _, k := range []float64{2.5, 3, 10} {
+		expected, _ := idx.Lookup(k)
+		offsets, err := disk.Lookup(k)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, offsets, k)
+	}
+	_, err = disk.Insert([]float64{7}, []uint64{7}, Source{}, store.Options{})
+	assert.Error(t, err)
+}
+
+func TestDiskIndex_Load(t *testing.T) {
+	// given
+	idx := New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98})
+	mem := store.NewMemBackend(nil)
+	FlushWith(idx, mem, store.Options{Layout: store.COLUMNAR})
+	disk, _ := OpenDisk(mem)
+
+	// when
+	loaded := disk.Load()
+
+	// then
+	assert.Equal(t, idx, loaded)
+	offsets, err := loaded.Lookup(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, offsets)
+}
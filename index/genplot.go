@@ -1,6 +1,7 @@
 package index
 
 import (
+	"fmt"
 	"image/color"
 
 	"gonum.org/v1/gonum/floats"
@@ -13,15 +14,22 @@ import (
 )
 
 /*
-Genplot takes an index and plots its keys, CDF, its approximation, and writes a plot.png file in assets/folder
+Genplot takes an index and plots its keys, CDF, its approximation, and writes the image at plotfilepath,
+its format given by the extension. The X axis is labelled after the indexed column and spans its keys
 */
-func Genplot(index *LearnedIndex, indexedCol []float64, plotfilepath string, roundedError bool) {
+func Genplot(index *LearnedIndex, column string, plotfilepath string, roundedError bool) error {
+	if index.Len == 0 {
+		return fmt.Errorf("the index has no key to plot")
+	}
 	linearRegFn := func(x float64) float64 { return index.M.Predict(x)*float64(index.Len) - 1 }
 	idxFromCDF := func(i float64) float64 { return stat.CDF(i, stat.Empirical, index.ST.Keys, nil)*float64(index.Len) - 1 }
 
-	p, _ := plot.New()
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
 	p.Title.Text = "Learned Index RMI"
-	p.X.Label.Text = "Age"
+	p.X.Label.Text = column
 	p.Y.Label.Text = "Index"
 
 	courbeKeys := plotter.XYs{}
@@ -61,10 +69,10 @@ func Genplot(index *LearnedIndex, indexedCol []float64, plotfilepath string, rou
 	p.Legend.Add("Approx (lr)", approxFn)
 	p.Add(cdfFn)
 	p.Legend.Add("CDF", cdfFn)
-	p.X.Min = 0
+	p.X.Min = floats.Min(index.ST.Keys)
 	p.X.Max = floats.Max(index.ST.Keys)
 	p.Y.Min = -float64(index.Len) / 10
 	p.Y.Max = float64(index.Len) * 1.5
 	p.Add(plotter.NewGrid())
-	p.Save(4*vg.Inch, 4*vg.Inch, plotfilepath)
+	return p.Save(4*vg.Inch, 4*vg.Inch, plotfilepath)
 }
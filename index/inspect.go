@@ -0,0 +1,98 @@
+package index
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/BenJoyenConseil/rmi/estimate/linear"
+	"github.com/BenJoyenConseil/rmi/store"
+)
+
+/*
+Report describes the internals of a DiskIndex, as printed by rmi inspect
+*/
+type Report struct {
+	Version     int                `json:"version"`
+	Layout      string             `json:"layout"`
+	Values      string             `json:"values"`
+	Records     int                `json:"records"` // the records of the delta included
+	Delta       int                `json:"delta"`
+	KeyMin      float64            `json:"key_min"`
+	KeyMax      float64            `json:"key_max"`
+	Estimator   string             `json:"estimator"`
+	Params      map[string]float64 `json:"params"`
+	MinErrBound int                `json:"min_err_bound"`
+	MaxErrBound int                `json:"max_err_bound"`
+	Models      []ModelStats       `json:"models"`
+	Sections    store.Sections     `json:"sections"`
+	Checksummed bool               `json:"checksummed"`
+	Source      string             `json:"source,omitempty"`
+	Column      string             `json:"column,omitempty"`
+}
+
+/*
+ModelStats measures how far the guesses of a model are from the positions of the keys it was fitted over
+*/
+type ModelStats struct {
+	Stage        int     `json:"stage"`
+	Keys         int     `json:"keys"`
+	MeanAbsError float64 `json:"mean_abs_error"`
+	MaxAbsError  int     `json:"max_abs_error"`
+	MeanWindow   float64 `json:"mean_window"` // records read by a lookup, once the window is clamped to the store
+}
+
+/*
+Inspect reads the header and all the keys of the store to describe the index
+*/
+func (idx *DiskIndex) Inspect() (r Report, err error) {
+	lr, ok := idx.M.(*linear.RegressionModel)
+	if !ok {
+		return r, fmt.Errorf("the model %T can't be inspected", idx.M)
+	}
+	if r.Version, err = idx.S.Version(); err != nil {
+		return r, err
+	}
+	if r.Sections, err = idx.S.Sections(); err != nil {
+		return r, err
+	}
+	r.Layout, r.Values = idx.S.Layout().String(), idx.Values.String()
+	r.Records, r.Delta = idx.Count(), idx.DeltaLen
+	r.Estimator, r.Params = "linear", map[string]float64{"intercept": lr.Intercept, "slope": lr.Slope}
+	r.MinErrBound, r.MaxErrBound = idx.MinErrBound, idx.MaxErrBound
+	r.Checksummed, r.Source, r.Column = idx.Checksummed, idx.Source.Path, idx.Source.Column
+
+	// a single stage of a single model, fitted over the records of the store
+	stats := ModelStats{Keys: idx.Len}
+	r.KeyMin, r.KeyMax = math.Inf(1), math.Inf(-1)
+	var absErrors, windows float64
+	for lo := 0; lo < idx.Len; lo += VERIFY_CHUNK {
+		hi := lo + VERIFY_CHUNK - 1
+		if hi > idx.Len-1 {
+			hi = idx.Len - 1
+		}
+		for i, key := range idx.S.GetKeys(int64(lo), int64(hi)) {
+			guess, lower, upper := idx.GuessIndex(key)
+			e := lo + i - guess
+			if e < 0 {
+				e = -e
+			}
+			if e > stats.MaxAbsError {
+				stats.MaxAbsError = e
+			}
+			absErrors += float64(e)
+			windows += float64(upper - lower + 1)
+			r.KeyMin, r.KeyMax = math.Min(r.KeyMin, key), math.Max(r.KeyMax, key)
+		}
+	}
+	for _, key := range idx.delta.Keys {
+		r.KeyMin, r.KeyMax = math.Min(r.KeyMin, key), math.Max(r.KeyMax, key)
+	}
+	if r.Records == 0 {
+		r.KeyMin, r.KeyMax = 0, 0
+	}
+	if idx.Len > 0 {
+		stats.MeanAbsError, stats.MeanWindow = absErrors/float64(idx.Len), windows/float64(idx.Len)
+	}
+	r.Models = []ModelStats{stats}
+	return r, nil
+}
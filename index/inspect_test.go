@@ -0,0 +1,37 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskIndex_Inspect(t *testing.T) {
+	// given
+	idx := New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98})
+	mem := store.NewMemBackend(nil)
+	FlushWith(idx, mem, store.Options{Layout: store.COLUMNAR})
+	disk, _ := OpenDisk(mem)
+
+	// when
+	r, err := disk.Inspect()
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, store.VERSION_META, r.Version)
+	assert.Equal(t, "columnar", r.Layout)
+	assert.Equal(t, "rows", r.Values)
+	assert.Equal(t, 7, r.Records)
+	assert.Equal(t, 2.5, r.KeyMin)
+	assert.Equal(t, 10., r.KeyMax)
+	assert.Equal(t, "linear", r.Estimator)
+	assert.Contains(t, r.Params, "slope")
+	assert.Equal(t, -2, r.MinErrBound)
+	assert.Equal(t, 2, r.MaxErrBound)
+	assert.Len(t, r.Models, 1)
+	assert.Equal(t, 7, r.Models[0].Keys)
+	assert.LessOrEqual(t, r.Models[0].MaxAbsError, 2)
+	assert.Equal(t, int64(7)*store.RECORD_LEN, r.Sections.Records)
+	assert.True(t, r.Checksummed)
+}
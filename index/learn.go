@@ -2,7 +2,6 @@ package index
 
 import (
 	"fmt"
-	"sort"
 
 	"github.com/BenJoyenConseil/rmi/estimate"
 	"github.com/BenJoyenConseil/rmi/estimate/linear"
@@ -25,14 +24,17 @@ New return an LearnedIndex fitted over the dataset with a linear regression algo
 func New(dataset []float64) *LearnedIndex {
 
 	st := search.NewSortedTable(dataset)
-	// store.Flush(st)
+	m, minErr, maxErr := fit(st.Keys)
+	return &LearnedIndex{M: m, Len: len(dataset), ST: st, MinErrBound: minErr, MaxErrBound: maxErr}
+}
 
-	x, y := linear.Cdf(st.Keys)
-	len_ := len(dataset)
-	m := linear.Fit(x, y)
+// fit learns the CDF of the sorted keys and returns the error bounds of the model's guesses
+func fit(keys []float64) (m *linear.RegressionModel, minErr, maxErr int) {
+	x, y := linear.Cdf(keys)
+	len_ := len(keys)
+	m = linear.Fit(x, y)
 	guesses := make([]int, len_)
 	scaledY := make([]int, len_)
-	maxErr, minErr := 0, 0
 	for i, k := range x {
 		guesses[i] = scale(m.Predict(k), len_)
 		scaledY[i] = scale(y[i], len_)
@@ -43,7 +45,7 @@ func New(dataset []float64) *LearnedIndex {
 			minErr = residual
 		}
 	}
-	return &LearnedIndex{M: m, Len: len_, ST: st, MinErrBound: minErr, MaxErrBound: maxErr}
+	return m, minErr, maxErr
 }
 
 /*
@@ -52,51 +54,44 @@ and upper / lower positions' search interval. Guess, lower and upper
 always have values between 0 and len(keys)-1
 */
 func (idx *LearnedIndex) GuessIndex(key float64) (guess, lower, upper int) {
-	guess = scale(idx.M.Predict(key), idx.Len)
-	lower = idx.MinErrBound + guess
+	return guessIndex(idx.M, idx.Len, idx.MinErrBound, idx.MaxErrBound, key)
+}
+
+func guessIndex(m estimate.Estimator, len_, minErr, maxErr int, key float64) (guess, lower, upper int) {
+	guess = scale(m.Predict(key), len_)
+	lower = minErr + guess
 	if lower < 0 {
 		lower = 0
-	} else if lower > idx.Len-1 {
-		lower = idx.Len - 1
+	} else if lower > len_-1 {
+		lower = len_ - 1
 	}
-	upper = guess + idx.MaxErrBound
-	if upper > idx.Len-1 {
-		upper = idx.Len - 1
+	upper = guess + maxErr
+	if upper > len_-1 {
+		upper = len_ - 1
 	} else if upper < 0 {
 		upper = 0
 	}
 
 	if guess < 0 {
 		guess = 0
-	} else if guess > idx.Len-1 {
-		guess = idx.Len - 1
+	} else if guess > len_-1 {
+		guess = len_ - 1
 	}
 	return guess, lower, upper
 }
 
 /*
-Lookup return the first offsets of the key or err if the key is not found in the index
+Lookup return the offsets of the key or err if the key is not found in the index.
+The error window is widened to all the duplicates of the key
 */
 func (idx *LearnedIndex) Lookup(key float64) (offsets []int, err error) {
-	guess, lower, upper := idx.GuessIndex(key)
-	i := 0
-	// k, o, err := store.Get(guess_i)
-	// st, err := store.STExtract(guess_i+1, upper+1)
-
-	if key > idx.ST.Keys[guess] {
-		subKeys := idx.ST.Keys[guess+1 : upper+1]
-		i = sort.SearchFloat64s(subKeys, key) + guess + 1
-	} else if key <= idx.ST.Keys[guess] {
-		subKeys := idx.ST.Keys[lower : guess+1]
-		i = sort.SearchFloat64s(subKeys, key) + lower
-	}
-
-	// iterate to get all equal keys
-	for ; i < upper+1; i++ {
-		if idx.ST.Keys[i] == key {
-			offsets = append(offsets, idx.ST.Offsets[i])
-		} else {
-			break
+	if idx.Len > 0 {
+		_, lower, upper := idx.GuessIndex(key)
+		keys := idx.ST.Keys
+		lower, upper = widen(key, lower, upper, idx.Len, func(i int) float64 { return keys[i] })
+		i, j := equalRange(upper-lower+1, key, func(i int) float64 { return keys[lower+i] })
+		for ; i < j; i++ {
+			offsets = append(offsets, idx.ST.Offsets[lower+i])
 		}
 	}
 
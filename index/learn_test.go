@@ -122,3 +122,22 @@ func ExampleLearnedIndex() {
 	// The key 5.000000 is located [0]
 	// The key 10.000000 is located [4]
 }
+
+func TestLookup_Duplicates(t *testing.T) {
+	// given many duplicates, spreading beyond the error window of the model
+	keys := make([]float64, 1000)
+	for i := range keys {
+		keys[i] = float64(i % 3)
+	}
+	idx := New(keys)
+
+	// when
+	offsets, err := idx.Lookup(1)
+
+	// then every row holding the key is returned
+	assert.NoError(t, err)
+	assert.Len(t, offsets, 333)
+	for _, o := range offsets {
+		assert.Equal(t, 1, o%3)
+	}
+}
@@ -0,0 +1,473 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/BenJoyenConseil/rmi/store"
+)
+
+const (
+	MEMTABLE_LEN = 4096            // records kept in memory before they are flushed to a segment
+	COMPACT_AT   = 4               // a compaction starts in the background once there are this many segments
+	TOMBSTONE    = ^uint64(0)      // the value of a deleted key, it can't be Put
+	SEGMENTS     = "segments.json" // lists the live segments of an LSMIndex, the newest first
+)
+
+/*
+LSMIndex maps unique keys to values and supports continuous writes.
+The writes go to a mutable memtable, which is flushed as an immutable sorted segment once it holds MemtableLen keys.
+Each segment is a store file with its own learned model. A lookup searches the memtable, then the segments from
+the newest to the oldest, the first record found wins. A deleted key is written as a TOMBSTONE hiding the older
+records. Once there are CompactAt segments, a background compaction merges them into one, retrains its model
+and drops the tombstones.
+The memtable is not logged: the writes are durable once Flush or Close returns
+*/
+type LSMIndex struct {
+	MemtableLen int
+	CompactAt   int
+
+	dir        string
+	opts       store.Options
+	mu         sync.RWMutex
+	memtable   map[float64]uint64
+	segments   []*segment // newest first
+	next       int        // sequence number of the next segment file
+	compacting bool
+	compacted  *sync.Cond // broadcast on mu when a compaction ends
+	err        error      // of the last background compaction
+}
+
+type segment struct {
+	name, path string
+	idx        *DiskIndex
+}
+
+type segmentsManifest struct {
+	Next     int      `json:"next"`
+	Segments []string `json:"segments"`
+}
+
+/*
+OpenLSM opens the LSMIndex whose segments are in dir, the directory is created if needed.
+The segments are written with opts. The segment files which are not live anymore,
+left by a flush or a compaction which didn't complete, are removed
+*/
+func OpenLSM(dir string, opts store.Options) (*LSMIndex, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	l := &LSMIndex{MemtableLen: MEMTABLE_LEN, CompactAt: COMPACT_AT, dir: dir, opts: opts, memtable: map[float64]uint64{}}
+	l.compacted = sync.NewCond(&l.mu)
+	var m segmentsManifest
+	b, err := ioutil.ReadFile(filepath.Join(dir, SEGMENTS))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("%s: %s", filepath.Join(dir, SEGMENTS), err)
+		}
+	}
+	l.next = m.Next
+	live := map[string]bool{}
+	for _, name := range m.Segments {
+		seg, err := l.openSegment(name)
+		if err != nil {
+			l.closeSegments()
+			return nil, err
+		}
+		l.segments = append(l.segments, seg)
+		live[name] = true
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "seg-*.rmi"))
+	for _, f := range files {
+		if !live[filepath.Base(f)] {
+			os.Remove(f)
+		}
+	}
+	return l, nil
+}
+
+/*
+Put maps the key to value, replacing its previous value
+*/
+func (l *LSMIndex) Put(key float64, value uint64) error {
+	if value == TOMBSTONE {
+		return fmt.Errorf("the value %d is reserved to the deleted keys", value)
+	}
+	return l.write(key, value)
+}
+
+/*
+Delete removes the key, it is hidden by a TOMBSTONE until the compaction drops it
+*/
+func (l *LSMIndex) Delete(key float64) error {
+	return l.write(key, TOMBSTONE)
+}
+
+func (l *LSMIndex) write(key float64, value uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.memtable[key] = value
+	if len(l.memtable) < l.MemtableLen {
+		return nil
+	}
+	return l.flush()
+}
+
+/*
+Get returns the value of the key, found is false when the key was never written or was deleted
+*/
+func (l *LSMIndex) Get(key float64) (value uint64, found bool, err error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if v, ok := l.memtable[key]; ok {
+		return v, v != TOMBSTONE, nil
+	}
+	for _, seg := range l.segments {
+		if v, ok := seg.idx.value(key); ok {
+			return v, v != TOMBSTONE, nil
+		}
+	}
+	return 0, false, nil
+}
+
+/*
+Segments returns the number of segments on disk
+*/
+func (l *LSMIndex) Segments() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.segments)
+}
+
+/*
+Flush writes the memtable to a new segment
+*/
+func (l *LSMIndex) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.flush()
+}
+
+// flush writes the memtable to a new segment, l.mu must be locked
+func (l *LSMIndex) flush() error {
+	if len(l.memtable) == 0 {
+		return nil
+	}
+	keys := make([]float64, 0, len(l.memtable))
+	for k := range l.memtable {
+		keys = append(keys, k)
+	}
+	sort.Float64s(keys)
+	values := make([]uint64, len(keys))
+	for i, k := range keys {
+		values[i] = l.memtable[k]
+	}
+	seg, err := l.writeSegment(l.segmentName(), keys, values)
+	if err != nil {
+		return err
+	}
+	segments := append([]*segment{seg}, l.segments...)
+	if err := l.saveSegments(segments); err != nil {
+		seg.close(true)
+		return err
+	}
+	l.segments, l.memtable = segments, map[float64]uint64{}
+	if len(l.segments) >= l.CompactAt && !l.compacting {
+		l.compacting = true
+		go func() {
+			err := l.compact()
+			l.mu.Lock()
+			l.compacting, l.err = false, err
+			l.compacted.Broadcast()
+			l.mu.Unlock()
+		}()
+	}
+	return nil
+}
+
+/*
+Compact merges all the segments into one, waiting for the compaction running in the background
+or called by another goroutine
+*/
+func (l *LSMIndex) Compact() error {
+	l.mu.Lock()
+	for l.compacting {
+		l.compacted.Wait()
+	}
+	if err := l.err; err != nil {
+		l.err = nil
+		l.mu.Unlock()
+		return err
+	}
+	l.compacting = true
+	l.mu.Unlock()
+	err := l.compact()
+	l.mu.Lock()
+	l.compacting = false
+	l.compacted.Broadcast()
+	l.mu.Unlock()
+	return err
+}
+
+// compact merges the segments present when it starts. The segments flushed meanwhile are newer, they stay in front
+func (l *LSMIndex) compact() error {
+	l.mu.Lock()
+	merged := append([]*segment(nil), l.segments...)
+	name := l.segmentName()
+	l.mu.Unlock()
+	if len(merged) < 2 && (len(merged) == 0 || merged[0].idx.tombstones() == 0) {
+		return nil
+	}
+
+	// the segments are immutable, they are read without holding the lock
+	keys, values := mergeSegments(merged)
+	var seg *segment
+	if len(keys) > 0 {
+		var err error
+		if seg, err = l.writeSegment(name, keys, values); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	segments := append([]*segment(nil), l.segments[:len(l.segments)-len(merged)]...)
+	if seg != nil {
+		segments = append(segments, seg)
+	}
+	if err := l.saveSegments(segments); err != nil {
+		if seg != nil {
+			seg.close(true)
+		}
+		return err
+	}
+	l.segments = segments
+	for _, s := range merged {
+		s.close(true)
+	}
+	return nil
+}
+
+// mergeSegments returns the latest record of each key, the tombstones dropped
+func mergeSegments(segments []*segment) (keys []float64, values []uint64) {
+	cursors := make([]delta, len(segments))
+	for i, s := range segments {
+		if s.idx.Len > 0 {
+			cursors[i] = delta{Keys: s.idx.S.GetKeys(0, int64(s.idx.Len-1)), Values: s.idx.S.GetValues(0, int64(s.idx.Len-1))}
+		}
+	}
+	mergeNewest(cursors, func(key float64, value uint64) {
+		keys, values = append(keys, key), append(values, value)
+	})
+	return keys, values
+}
+
+// mergeNewest calls fn with the keys of the sorted cursors in ascending order, with the value of the first cursor
+// holding the key. The keys whose value is a TOMBSTONE are skipped
+func mergeNewest(cursors []delta, fn func(key float64, value uint64)) {
+	for {
+		newest := -1
+		for i, c := range cursors {
+			if len(c.Keys) > 0 && (newest < 0 || c.Keys[0] < cursors[newest].Keys[0]) {
+				newest = i
+			}
+		}
+		if newest < 0 {
+			return
+		}
+		key, value := cursors[newest].Keys[0], cursors[newest].Values[0]
+		for i := range cursors {
+			if len(cursors[i].Keys) > 0 && cursors[i].Keys[0] == key {
+				cursors[i].Keys, cursors[i].Values = cursors[i].Keys[1:], cursors[i].Values[1:]
+			}
+		}
+		if value != TOMBSTONE {
+			fn(key, value)
+		}
+	}
+}
+
+/*
+Scan calls fn with the keys between lo and hi (both included) in ascending order, and their latest value,
+until fn returns false. The records are read first, so fn can write to the index
+*/
+func (l *LSMIndex) Scan(lo, hi float64, fn func(key float64, value uint64) bool) error {
+	l.mu.RLock()
+	cursors := make([]delta, 0, len(l.segments)+1)
+	mem := delta{}
+	for k, v := range l.memtable {
+		if k >= lo && k <= hi {
+			mem.Keys, mem.Values = append(mem.Keys, k), append(mem.Values, v)
+		}
+	}
+	sort.Sort(mem)
+	cursors = append(cursors, mem)
+	for _, s := range l.segments {
+		cursors = append(cursors, s.idx.between(lo, hi))
+	}
+	l.mu.RUnlock()
+
+	var keys []float64
+	var values []uint64
+	mergeNewest(cursors, func(key float64, value uint64) {
+		keys, values = append(keys, key), append(values, value)
+	})
+	for i := range keys {
+		if !fn(keys[i], values[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+/*
+Close flushes the memtable, waits for the running compaction and closes the segments
+*/
+func (l *LSMIndex) Close() error {
+	err := l.Flush()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.compacting {
+		l.compacted.Wait()
+	}
+	if err == nil {
+		err = l.err
+	}
+	l.closeSegments()
+	return err
+}
+
+func (l *LSMIndex) closeSegments() {
+	for _, s := range l.segments {
+		s.close(false)
+	}
+	l.segments = nil
+}
+
+// writeSegment writes the sorted unique keys and their values to a new segment file with the model fitted over them
+func (l *LSMIndex) writeSegment(name string, keys []float64, values []uint64) (*segment, error) {
+	f, err := os.OpenFile(filepath.Join(l.dir, name), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	m, minErr, maxErr := fit(keys)
+	_, err = write(store.NewFileBackend(f), &Meta{M: m, Len: len(keys), MinErrBound: minErr, MaxErrBound: maxErr, Values: VALUES}, l.opts, keys, values)
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return l.openSegment(name)
+}
+
+// segmentName returns the name of a new segment file, l.mu must be locked
+func (l *LSMIndex) segmentName() string {
+	l.next++
+	return fmt.Sprintf("seg-%06d.rmi", l.next-1)
+}
+
+func (l *LSMIndex) openSegment(name string) (*segment, error) {
+	f, err := os.Open(filepath.Join(l.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	idx, err := OpenDisk(store.NewFileBackend(f))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if idx.Values != VALUES {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a segment of an LSMIndex", f.Name())
+	}
+	return &segment{name: name, path: f.Name(), idx: idx}, nil
+}
+
+// saveSegments writes the manifest listing the segments, through a temporary file renamed over the previous one
+func (l *LSMIndex) saveSegments(segments []*segment) error {
+	m := segmentsManifest{Next: l.next, Segments: []string{}}
+	for _, s := range segments {
+		m.Segments = append(m.Segments, s.name)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(l.dir, SEGMENTS+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(l.dir, SEGMENTS))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (s *segment) close(remove bool) {
+	s.idx.S.Close()
+	if remove {
+		os.Remove(s.path)
+	}
+}
+
+// value returns the value of a unique key
+func (idx *DiskIndex) value(key float64) (uint64, bool) {
+	if idx.Len == 0 {
+		return 0, false
+	}
+	_, lower, upper := idx.GuessIndex(key)
+	keys := idx.S.GetKeys(int64(lower), int64(upper))
+	i, j := equalRange(len(keys), key, func(i int) float64 { return keys[i] })
+	if i == j {
+		return 0, false
+	}
+	return idx.S.GetValues(int64(lower+i), int64(lower+i))[0], true
+}
+
+// tombstones returns the number of deleted keys of a segment
+func (idx *DiskIndex) tombstones() (n int) {
+	if idx.Len == 0 {
+		return 0
+	}
+	for _, v := range idx.S.GetValues(0, int64(idx.Len-1)) {
+		if v == TOMBSTONE {
+			n++
+		}
+	}
+	return n
+}
+
+// between reads the records whose key is between lo and hi (both included).
+// The model being monotonic, the first key >= lo is at most one position after the error window of lo
+func (idx *DiskIndex) between(lo, hi float64) (d delta) {
+	if idx.Len == 0 || lo > hi {
+		return d
+	}
+	first := idx.lowerBound(lo, func(k float64) bool { return k >= lo })
+	end := idx.lowerBound(hi, func(k float64) bool { return k > hi })
+	if first >= end {
+		return d
+	}
+	return delta{Keys: idx.S.GetKeys(int64(first), int64(end-1)), Values: idx.S.GetValues(int64(first), int64(end-1))}
+}
@@ -0,0 +1,218 @@
+package index
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLSMIndex(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	l, err := OpenLSM(dir, store.Options{})
+	assert.NoError(t, err)
+	l.MemtableLen, l.CompactAt = 3, 100
+
+	// when
+	for i, k := range []float64{5, 3, 10, 2.5, 3.14, 2.98} {
+		assert.NoError(t, l.Put(k, uint64(i)))
+	}
+	assert.NoError(t, l.Put(5, 42))
+	assert.NoError(t, l.Delete(10))
+
+	// then the memtable was flushed twice and the newest values win
+	assert.Equal(t, 2, l.Segments())
+	v, found, err := l.Get(5)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, uint64(42), v)
+	v, found, _ = l.Get(2.98)
+	assert.True(t, found)
+	assert.Equal(t, uint64(5), v)
+	_, found, _ = l.Get(10)
+	assert.False(t, found)
+	_, found, _ = l.Get(7)
+	assert.False(t, found)
+
+	// when closed then opened again
+	assert.NoError(t, l.Close())
+	l, err = OpenLSM(dir, store.Options{})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 3, l.Segments())
+	v, found, _ = l.Get(5)
+	assert.True(t, found)
+	assert.Equal(t, uint64(42), v)
+	_, found, _ = l.Get(10)
+	assert.False(t, found)
+
+	// when compacted
+	assert.NoError(t, l.Compact())
+
+	// then the tombstone is dropped
+	assert.Equal(t, 1, l.Segments())
+	assert.Equal(t, 0, l.segments[0].idx.tombstones())
+	assert.Equal(t, 5, l.segments[0].idx.Len)
+	v, found, _ = l.Get(5)
+	assert.True(t, found)
+	assert.Equal(t, uint64(42), v)
+	_, found, _ = l.Get(10)
+	assert.False(t, found)
+	files, _ := filepath.Glob(filepath.Join(dir, "seg-*.rmi"))
+	assert.Len(t, files, 1)
+	assert.NoError(t, l.Close())
+}
+
+func TestLSMIndex_BackgroundCompaction(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	l, _ := OpenLSM(dir, store.Options{Layout: store.COMPRESSED})
+	l.MemtableLen, l.CompactAt = 50, 3
+	expected := map[float64]uint64{}
+	r := rand.New(rand.NewSource(1))
+
+	// when
+	for i := 0; i < 2000; i++ {
+		k := float64(r.Intn(500))
+		if r.Intn(5) == 0 {
+			assert.NoError(t, l.Delete(k))
+			delete(expected, k)
+		} else {
+			assert.NoError(t, l.Put(k, uint64(i)))
+			expected[k] = uint64(i)
+		}
+	}
+
+	// then
+	assert.NoError(t, l.Compact())
+	assert.Equal(t, 1, l.Segments())
+	for k := 0.; k < 500; k++ {
+		v, found, err := l.Get(k)
+		assert.NoError(t, err)
+		e, ok := expected[k]
+		assert.Equal(t, ok, found, k)
+		if ok {
+			assert.Equal(t, e, v, k)
+		}
+	}
+	assert.NoError(t, l.Close())
+}
+
+func TestLSMIndex_ConcurrentCompactions(t *testing.T) {
+	// given
+	l, _ := OpenLSM(t.TempDir(), store.Options{})
+	l.MemtableLen, l.CompactAt = 10, 100
+	for i := 0; i < 300; i++ {
+		assert.NoError(t, l.Put(float64(i), uint64(i)))
+	}
+
+	// when several goroutines compact at once
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, l.Compact())
+		}()
+	}
+	wg.Wait()
+
+	// then each compaction waits for the running one
+	assert.Equal(t, 1, l.Segments())
+	v, found, err := l.Get(150)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, uint64(150), v)
+	assert.NoError(t, l.Close())
+}
+
+func TestLSMIndex_Scan(t *testing.T) {
+	// given keys spread over the memtable and several segments
+	l, _ := OpenLSM(t.TempDir(), store.Options{})
+	defer l.Close()
+	l.MemtableLen, l.CompactAt = 40, 100
+	expected := map[float64]uint64{}
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 500; i++ {
+		k := float64(r.Intn(300)) / 2
+		if r.Intn(4) == 0 {
+			l.Delete(k)
+			delete(expected, k)
+		} else {
+			l.Put(k, uint64(i))
+			expected[k] = uint64(i)
+		}
+	}
+
+	for _, bounds := range [][2]float64{{-10, 200}, {10, 20.5}, {33.3, 33.7}, {149.5, 300}, {20, 10}} {
+		// when
+		var keys []float64
+		var values []uint64
+		err := l.Scan(bounds[0], bounds[1], func(k float64, v uint64) bool {
+			keys, values = append(keys, k), append(values, v)
+			return true
+		})
+
+		// then
+		assert.NoError(t, err)
+		var expectedKeys []float64
+		for k := range expected {
+			if k >= bounds[0] && k <= bounds[1] {
+				expectedKeys = append(expectedKeys, k)
+			}
+		}
+		sort.Float64s(expectedKeys)
+		assert.Equal(t, expectedKeys, keys, bounds)
+		for i, k := range keys {
+			assert.Equal(t, expected[k], values[i], k)
+		}
+	}
+
+	// when stopped early
+	n := 0
+	l.Scan(0, 200, func(k float64, v uint64) bool {
+		n++
+		return n < 3
+	})
+	// then
+	assert.Equal(t, 3, n)
+}
+
+func TestOpenLSM_RemovesDeadSegments(t *testing.T) {
+	// given a segment left by a compaction which didn't complete
+	dir := t.TempDir()
+	l, _ := OpenLSM(dir, store.Options{})
+	l.Put(1, 1)
+	l.Close()
+	ioutil.WriteFile(filepath.Join(dir, "seg-000042.rmi"), []byte("garbage"), 0644)
+
+	// when
+	l, err := OpenLSM(dir, store.Options{})
+
+	// then
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "seg-000042.rmi"))
+	v, found, _ := l.Get(1)
+	assert.True(t, found)
+	assert.Equal(t, uint64(1), v)
+	l.Close()
+}
+
+func TestLSMIndex_Errors(t *testing.T) {
+	// given
+	l, _ := OpenLSM(t.TempDir(), store.Options{})
+	defer l.Close()
+
+	// when
+	err := l.Put(1, TOMBSTONE)
+
+	// then
+	assert.Error(t, err)
+}
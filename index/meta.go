@@ -0,0 +1,218 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/estimate"
+	"github.com/BenJoyenConseil/rmi/estimate/linear"
+	"github.com/BenJoyenConseil/rmi/table"
+)
+
+const (
+	LINEAR_MODEL = uint64(1)
+	META_LEN     = 40 // model type + len + min error + max error + model length, followed by the model bytes
+	FIELD_HEADER = 8  // tag + length of the optional fields written after the model
+
+	META_VALUES      = uint32(1)
+	META_SOURCE      = uint32(2)
+	META_COLUMN      = uint32(3)
+	META_SOURCE_STAT = uint32(4)
+	META_DELTA       = uint32(5)
+	META_CHECKSUM    = uint32(6)
+	META_FORMAT      = uint32(7)
+
+	SOURCE_STAT_LEN = 8 + 8 + sha256.Size // size + mtime + content hash
+	DELTA_LEN       = 16                  // offset + count of the delta records
+	CHECKSUM_LEN    = 8                   // checksum of the records + checksum of the delta
+	FORMAT_LEN      = 24                  // delimiter, quote, header, bad values policy, padding + field + default key
+)
+
+/*
+ValueKind tells what the values of the store records locate
+*/
+type ValueKind uint32
+
+const (
+	ROWS     ValueKind = iota // the position of the row in the source, the header excluded
+	POINTERS                  // a table.Pointer to the bytes of the row in the source
+	VALUES                    // opaque values, mapped to unique keys by an LSMIndex
+)
+
+func (k ValueKind) String() string {
+	switch k {
+	case ROWS:
+		return "rows"
+	case POINTERS:
+		return "pointers"
+	case VALUES:
+		return "values"
+	}
+	return fmt.Sprintf("ValueKind(%d)", uint32(k))
+}
+
+/*
+Meta describes a learned index written inside the meta section of a store file
+*/
+type Meta struct {
+	M                        estimate.Estimator
+	Len                      int
+	MinErrBound, MaxErrBound int
+	Values                   ValueKind
+	Source                   Source
+	DeltaOffset              int64 // the records inserted since the model was fitted are written there
+	DeltaLen                 int
+	Checksummed              bool   // the files written before the checksums were recorded have none
+	Checksum, DeltaChecksum  uint32 // store.Checksum of the record section and of the delta
+}
+
+func encodeMeta(meta Meta) ([]byte, error) {
+	lr, ok := meta.M.(*linear.RegressionModel)
+	if !ok {
+		return nil, fmt.Errorf("the model %T can't be written on disk", meta.M)
+	}
+	model, err := lr.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, META_LEN, META_LEN+len(model))
+	binary.LittleEndian.PutUint64(b[0:], LINEAR_MODEL)
+	binary.LittleEndian.PutUint64(b[8:], uint64(meta.Len))
+	binary.LittleEndian.PutUint64(b[16:], uint64(meta.MinErrBound))
+	binary.LittleEndian.PutUint64(b[24:], uint64(meta.MaxErrBound))
+	binary.LittleEndian.PutUint64(b[32:], uint64(len(model)))
+	b = append(b, model...)
+
+	values := make([]byte, 4)
+	binary.LittleEndian.PutUint32(values, uint32(meta.Values))
+	b = appendField(b, META_VALUES, values)
+	if meta.Source.Path != "" {
+		b = appendField(b, META_SOURCE, []byte(meta.Source.Path))
+	}
+	if meta.Source.Column != "" {
+		b = appendField(b, META_COLUMN, []byte(meta.Source.Column))
+	}
+	if meta.Source.Path != "" {
+		f := meta.Source.Format
+		format := make([]byte, FORMAT_LEN)
+		format[0], format[1], format[3] = f.Comma, f.Quote, byte(f.BadValues)
+		if f.Header {
+			format[2] = 1
+		}
+		binary.LittleEndian.PutUint64(format[8:], uint64(f.Field))
+		binary.LittleEndian.PutUint64(format[16:], math.Float64bits(f.Default))
+		b = appendField(b, META_FORMAT, format)
+	}
+	if meta.Source.Hash != nil {
+		stat := make([]byte, SOURCE_STAT_LEN)
+		binary.LittleEndian.PutUint64(stat, uint64(meta.Source.Size))
+		binary.LittleEndian.PutUint64(stat[8:], uint64(meta.Source.ModTime.UnixNano()))
+		copy(stat[16:], meta.Source.Hash)
+		b = appendField(b, META_SOURCE_STAT, stat)
+	}
+	// always written so that inserting records rewrites a meta section of the same length
+	delta := make([]byte, DELTA_LEN)
+	binary.LittleEndian.PutUint64(delta, uint64(meta.DeltaOffset))
+	binary.LittleEndian.PutUint64(delta[8:], uint64(meta.DeltaLen))
+	b = appendField(b, META_DELTA, delta)
+	if meta.Checksummed {
+		checksum := make([]byte, CHECKSUM_LEN)
+		binary.LittleEndian.PutUint32(checksum, meta.Checksum)
+		binary.LittleEndian.PutUint32(checksum[4:], meta.DeltaChecksum)
+		b = appendField(b, META_CHECKSUM, checksum)
+	}
+	return b, nil
+}
+
+func decodeMeta(b []byte) (meta Meta, err error) {
+	if len(b) < META_LEN {
+		return meta, fmt.Errorf("the meta section is too short: %d bytes", len(b))
+	}
+	if kind := binary.LittleEndian.Uint64(b); kind != LINEAR_MODEL {
+		return meta, fmt.Errorf("unknown model type %d", kind)
+	}
+	modelLen := int(binary.LittleEndian.Uint64(b[32:]))
+	if len(b) < META_LEN+modelLen {
+		return meta, fmt.Errorf("the meta section is too short for a %d bytes model", modelLen)
+	}
+	lr := &linear.RegressionModel{}
+	if err = lr.UnmarshalBinary(b[META_LEN : META_LEN+modelLen]); err != nil {
+		return meta, err
+	}
+	meta.M = lr
+	meta.Len = int(binary.LittleEndian.Uint64(b[8:]))
+	meta.MinErrBound = int(int64(binary.LittleEndian.Uint64(b[16:])))
+	meta.MaxErrBound = int(int64(binary.LittleEndian.Uint64(b[24:])))
+
+	// the optional fields, unknown tags are skipped
+	formatted := false
+	for b = b[META_LEN+modelLen:]; len(b) > 0; {
+		if len(b) < FIELD_HEADER {
+			return meta, fmt.Errorf("the meta section ends with a truncated field")
+		}
+		tag, n := binary.LittleEndian.Uint32(b), int(binary.LittleEndian.Uint32(b[4:]))
+		if len(b) < FIELD_HEADER+n {
+			return meta, fmt.Errorf("the field %d of the meta section is truncated", tag)
+		}
+		field := b[FIELD_HEADER : FIELD_HEADER+n]
+		switch tag {
+		case META_VALUES:
+			if n != 4 {
+				return meta, fmt.Errorf("the value kind is encoded on 4 bytes, got %d", n)
+			}
+			meta.Values = ValueKind(binary.LittleEndian.Uint32(field))
+		case META_SOURCE:
+			meta.Source.Path = string(field)
+		case META_COLUMN:
+			meta.Source.Column = string(field)
+		case META_SOURCE_STAT:
+			if n != SOURCE_STAT_LEN {
+				return meta, fmt.Errorf("the source stat is encoded on %d bytes, got %d", SOURCE_STAT_LEN, n)
+			}
+			meta.Source.Size = int64(binary.LittleEndian.Uint64(field))
+			meta.Source.ModTime = time.Unix(0, int64(binary.LittleEndian.Uint64(field[8:]))).UTC()
+			meta.Source.Hash = append([]byte(nil), field[16:]...)
+		case META_DELTA:
+			if n != DELTA_LEN {
+				return meta, fmt.Errorf("the delta is encoded on %d bytes, got %d", DELTA_LEN, n)
+			}
+			meta.DeltaOffset = int64(binary.LittleEndian.Uint64(field))
+			meta.DeltaLen = int(binary.LittleEndian.Uint64(field[8:]))
+		case META_FORMAT:
+			if n != FORMAT_LEN {
+				return meta, fmt.Errorf("the format is encoded on %d bytes, got %d", FORMAT_LEN, n)
+			}
+			formatted = true
+			meta.Source.Format = table.Format{
+				Comma:     field[0],
+				Quote:     field[1],
+				Header:    field[2] == 1,
+				BadValues: table.BadValues(field[3]),
+				Field:     int(int64(binary.LittleEndian.Uint64(field[8:]))),
+				Default:   math.Float64frombits(binary.LittleEndian.Uint64(field[16:])),
+			}
+		case META_CHECKSUM:
+			if n != CHECKSUM_LEN {
+				return meta, fmt.Errorf("the checksums are encoded on %d bytes, got %d", CHECKSUM_LEN, n)
+			}
+			meta.Checksummed = true
+			meta.Checksum = binary.LittleEndian.Uint32(field)
+			meta.DeltaChecksum = binary.LittleEndian.Uint32(field[4:])
+		}
+		b = b[FIELD_HEADER+n:]
+	}
+	if meta.Source.Path != "" && !formatted {
+		meta.Source.Format = table.DefaultFormat()
+	}
+	return meta, nil
+}
+
+func appendField(b []byte, tag uint32, field []byte) []byte {
+	h := make([]byte, FIELD_HEADER)
+	binary.LittleEndian.PutUint32(h, tag)
+	binary.LittleEndian.PutUint32(h[4:], uint32(len(field)))
+	return append(append(b, h...), field...)
+}
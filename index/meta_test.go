@@ -0,0 +1,96 @@
+package index
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/estimate/linear"
+	"github.com/BenJoyenConseil/rmi/table"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecodeMeta(t *testing.T) {
+	// given
+	meta := Meta{
+		M:           &linear.RegressionModel{Intercept: .23119036646681634, Slope: .08523040437506509},
+		Len:         7,
+		MinErrBound: -2,
+		MaxErrBound: 2,
+		Values:      POINTERS,
+		Source: Source{
+			Path:    "data/people.csv",
+			Column:  "age",
+			Size:    95,
+			ModTime: time.Unix(1605468596, 123).UTC(),
+			Hash:    make([]byte, sha256.Size),
+			Format:  table.Format{Comma: ';', Quote: '\'', Field: 2, BadValues: table.DEFAULT, Default: -1},
+		},
+		DeltaOffset:   4096,
+		DeltaLen:      3,
+		Checksummed:   true,
+		Checksum:      0xcafe,
+		DeltaChecksum: 0xbeef,
+	}
+
+	// when
+	b, err := encodeMeta(meta)
+	decoded, errDecode := decodeMeta(b)
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, errDecode)
+	assert.Equal(t, meta, decoded)
+}
+
+func TestDecodeMeta_OptionalFields(t *testing.T) {
+	// given a meta section without optional fields
+	meta := Meta{M: &linear.RegressionModel{}, Len: 3}
+	b, _ := encodeMeta(meta)
+	b = b[:META_LEN+16]
+
+	// when
+	decoded, err := decodeMeta(b)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, ROWS, decoded.Values)
+	assert.Equal(t, Source{}, decoded.Source)
+
+	// when the tag is unknown
+	decoded, err = decodeMeta(appendField(b, 99, []byte("from the future")))
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, meta, decoded)
+
+	// when a field is truncated
+	_, err = decodeMeta(appendField(b, META_SOURCE, []byte("abc"))[:len(b)+9])
+	// then
+	assert.Error(t, err)
+	_, err = decodeMeta(append(b, 1, 0))
+	assert.Error(t, err)
+	_, err = decodeMeta(appendField(b, META_VALUES, []byte{1}))
+	assert.Error(t, err)
+	_, err = decodeMeta(appendField(b, META_SOURCE_STAT, []byte{1}))
+	assert.Error(t, err)
+	_, err = decodeMeta(appendField(b, META_DELTA, []byte{1}))
+	assert.Error(t, err)
+	_, err = decodeMeta(appendField(b, META_FORMAT, []byte{1}))
+	assert.Error(t, err)
+
+	// when the source was recorded without its format
+	decoded, err = decodeMeta(appendField(b, META_SOURCE, []byte("data/people.csv")))
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, table.DefaultFormat(), decoded.Source.Format)
+}
+
+func TestDecodeMeta_Errors(t *testing.T) {
+	_, err := decodeMeta([]byte{1, 0, 0})
+	assert.Error(t, err)
+
+	_, err = decodeMeta(make([]byte, META_LEN))
+	assert.Error(t, err)
+
+	_, err = encodeMeta(Meta{})
+	assert.Error(t, err)
+}
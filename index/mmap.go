@@ -0,0 +1,115 @@
+package index
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/BenJoyenConseil/rmi/store"
+)
+
+/*
+MmapIndex is a LearnedIndex searching the records of a memory-mapped store file.
+Opening it decodes the model only, the records are read by the lookups straight from the mapping
+*/
+type MmapIndex struct {
+	Meta
+	mapped *store.Mapped
+	delta  delta
+}
+
+/*
+OpenMmap maps the store file written by Flush at path
+*/
+func OpenMmap(path string) (*MmapIndex, error) {
+	m, err := store.Map(path)
+	if err != nil {
+		return nil, err
+	}
+	if m.Meta == nil {
+		m.Close()
+		return nil, fmt.Errorf("%s has no model, it must be written by index.Flush", path)
+	}
+	idx := &MmapIndex{mapped: m}
+	idx.Meta, err = decodeMeta(m.Meta)
+	if count := m.Count(); err == nil && idx.Len != count {
+		err = fmt.Errorf("the model is fitted over %d keys but the file holds %d records", idx.Len, count)
+	}
+	if err == nil {
+		idx.delta, err = readDelta(m, idx.DeltaOffset, idx.DeltaLen)
+	}
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	return idx, nil
+}
+
+/*
+GuessIndex return the predicted position of the key in the mapped records
+and upper / lower positions' search interval
+*/
+func (idx *MmapIndex) GuessIndex(key float64) (guess, lower, upper int) {
+	return guessIndex(idx.M, idx.Len, idx.MinErrBound, idx.MaxErrBound, key)
+}
+
+// keyAt returns the key of the record at position i, NaN when its block can't be decoded
+func (idx *MmapIndex) keyAt(i int) float64 {
+	switch idx.mapped.Layout {
+	case store.COLUMNAR:
+		return idx.mapped.Keys[i]
+	case store.COMPRESSED:
+		keys, _, err := idx.mapped.Range(int64(i), int64(i))
+		if err != nil {
+			return math.NaN()
+		}
+		return keys[0]
+	}
+	return idx.mapped.Entries[i].Key
+}
+
+/*
+Lookup searches the key between the error bounds, widened to all its duplicates,
+only the pages of this window are touched
+*/
+func (idx *MmapIndex) Lookup(key float64) (offsets []int, err error) {
+	if idx.Len > 0 {
+		_, lower, upper := idx.GuessIndex(key)
+		lower, upper = widen(key, lower, upper, idx.Len, idx.keyAt)
+		switch idx.mapped.Layout {
+		case store.COLUMNAR:
+			keys := idx.mapped.Keys[lower : upper+1]
+			i, j := equalRange(len(keys), key, func(i int) float64 { return keys[i] })
+			for _, v := range idx.mapped.Values[lower+i : lower+j] {
+				offsets = append(offsets, int(v))
+			}
+		case store.COMPRESSED:
+			keys, values, err := idx.mapped.Range(int64(lower), int64(upper))
+			if err != nil {
+				return nil, err
+			}
+			i, j := equalRange(len(keys), key, func(i int) float64 { return keys[i] })
+			for _, v := range values[i:j] {
+				offsets = append(offsets, int(v))
+			}
+		default:
+			entries := idx.mapped.Entries[lower : upper+1]
+			i, j := equalRange(len(entries), key, func(i int) float64 { return entries[i].Key })
+			for ; i < j; i++ {
+				offsets = append(offsets, int(entries[i].Value))
+			}
+		}
+	}
+	offsets = append(offsets, idx.delta.lookup(key)...)
+
+	if len(offsets) == 0 {
+		err = fmt.Errorf("The following key <%f> is not found in the index", key)
+	}
+	return offsets, err
+}
+
+/*
+Close unmaps the store file
+*/
+func (idx *MmapIndex) Close() error {
+	return idx.mapped.Close()
+}
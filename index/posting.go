@@ -0,0 +1,54 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/BenJoyenConseil/rmi/estimate"
+	"github.com/BenJoyenConseil/rmi/search"
+)
+
+/*
+PostingIndex is a learned index fitted over the distinct keys of a dataset,
+each key pointing to the posting list of its offsets in ascending order.
+With many duplicates the model learns a smoother CDF, so the error bounds are tighter.
+It lives in memory only: it is not written to a store, nor built by the rmi commands
+*/
+type PostingIndex struct {
+	M                        estimate.Estimator
+	PT                       *search.PostingTable
+	Len                      int // number of distinct keys
+	MinErrBound, MaxErrBound int
+}
+
+/*
+NewPosting return a PostingIndex fitted over the distinct keys of the dataset
+*/
+func NewPosting(dataset []float64) *PostingIndex {
+	pt := search.NewPostingTable(dataset)
+	m, minErr, maxErr := fit(pt.Keys)
+	return &PostingIndex{M: m, PT: pt, Len: len(pt.Keys), MinErrBound: minErr, MaxErrBound: maxErr}
+}
+
+/*
+GuessIndex return the predicted position of the key among the distinct keys
+and upper / lower positions' search interval
+*/
+func (idx *PostingIndex) GuessIndex(key float64) (guess, lower, upper int) {
+	return guessIndex(idx.M, idx.Len, idx.MinErrBound, idx.MaxErrBound, key)
+}
+
+/*
+Lookup return the posting list of the key or err if the key is not found in the index.
+The list is shared with the index and must not be modified
+*/
+func (idx *PostingIndex) Lookup(key float64) (offsets []int, err error) {
+	if idx.Len > 0 {
+		_, lower, upper := idx.GuessIndex(key)
+		keys := idx.PT.Keys[lower : upper+1]
+		if i := sort.SearchFloat64s(keys, key); i < len(keys) && keys[i] == key {
+			return idx.PT.Postings[lower+i], nil
+		}
+	}
+	return nil, fmt.Errorf("The following key <%f> is not found in the index", key)
+}
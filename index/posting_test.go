@@ -0,0 +1,58 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPosting(t *testing.T) {
+	keys := []float64{5, 3, 3, 3.14, 10, 2.5, 3, 2.98}
+
+	// when
+	idx := NewPosting(keys)
+
+	// then
+	assert.Equal(t, 6, idx.Len)
+	assert.Equal(t, []float64{2.5, 2.98, 3, 3.14, 5, 10}, idx.PT.Keys)
+	assert.LessOrEqual(t, idx.MaxErrBound-idx.MinErrBound, New(keys).MaxErrBound-New(keys).MinErrBound)
+}
+
+func TestPostingLookup(t *testing.T) {
+	// given
+	idx := NewPosting([]float64{5, 3, 3, 3.14, 10, 2.5, 3, 2.98})
+
+	// when
+	offsets, err := idx.Lookup(3)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 6}, offsets)
+
+	// when
+	offsets, err = idx.Lookup(10)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []int{4}, offsets)
+
+	// when not in the index
+	offsets, err = idx.Lookup(3.5)
+	// then
+	assert.Error(t, err)
+	assert.Nil(t, offsets)
+
+	// when the index is empty
+	_, err = NewPosting(nil).Lookup(1)
+	// then
+	assert.Error(t, err)
+}
+
+func ExamplePostingIndex() {
+	index := NewPosting([]float64{23, 90, 23, 3, 45, 1, 1.5, 23})
+
+	offsets, _ := index.Lookup(23)
+	fmt.Println("23 is located at", offsets, "over", index.Len, "distinct keys")
+
+	// Output:
+	// 23 is located at [0 2 7] over 6 distinct keys
+}
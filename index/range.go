@@ -0,0 +1,93 @@
+package index
+
+import (
+	"sort"
+)
+
+const (
+	RANGE_CHUNK = 1024 // records read at once by Range
+)
+
+/*
+Interval bounds the keys of a Range, From and To are included unless they are excluded
+*/
+type Interval struct {
+	From, To               float64
+	ExcludeFrom, ExcludeTo bool
+}
+
+// after tells if k is after the lower bound of the interval
+func (i Interval) after(k float64) bool {
+	if i.ExcludeFrom {
+		return k > i.From
+	}
+	return k >= i.From
+}
+
+// beyond tells if k is after the upper bound of the interval
+func (i Interval) beyond(k float64) bool {
+	if i.ExcludeTo {
+		return k >= i.To
+	}
+	return k > i.To
+}
+
+/*
+Range calls fn with the records whose key is inside the interval, in key order, until fn returns false.
+The model locates the first record, then the records are read by chunks until the upper bound.
+The records of the delta are merged in, after the records of the store holding the same key
+*/
+func (idx *DiskIndex) Range(in Interval, fn func(key float64, value uint64) bool) {
+	d := idx.delta
+	i := sort.Search(len(d.Keys), func(i int) bool { return in.after(d.Keys[i]) })
+	emitDelta := func(before func(k float64) bool) bool {
+		for ; i < len(d.Keys) && !in.beyond(d.Keys[i]) && before(d.Keys[i]); i++ {
+			if !fn(d.Keys[i], d.Values[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if idx.Len > 0 {
+		last := int64(idx.Len - 1)
+		for pos := int64(idx.lowerBound(in.From, in.after)); pos <= last; pos += RANGE_CHUNK {
+			end := pos + RANGE_CHUNK - 1
+			if end > last {
+				end = last
+			}
+			keys, values := idx.S.GetKeys(pos, end), idx.S.GetValues(pos, end)
+			for j, k := range keys {
+				if in.beyond(k) {
+					emitDelta(func(float64) bool { return true })
+					return
+				}
+				if !emitDelta(func(dk float64) bool { return dk < k }) || !fn(k, values[j]) {
+					return
+				}
+			}
+		}
+	}
+	emitDelta(func(float64) bool { return true })
+}
+
+/*
+lowerBound returns the first position whose key satisfies after, a predicate true from a position to the end.
+It is searched inside the error window of key, widened while the window doesn't hold that position:
+the error bounds only cover the last of the duplicated keys
+*/
+func (idx *DiskIndex) lowerBound(key float64, after func(k float64) bool) int {
+	_, lower, upper := idx.GuessIndex(key)
+	lo, hi, last := int64(lower), int64(upper), int64(idx.Len-1)
+	for step := hi - lo + 1; lo > 0 && after(idx.S.GetKeys(lo, lo)[0]); step *= 2 {
+		if lo -= step; lo < 0 {
+			lo = 0
+		}
+	}
+	for step := hi - lo + 1; hi < last && !after(idx.S.GetKeys(hi, hi)[0]); step *= 2 {
+		if hi += step; hi > last {
+			hi = last
+		}
+	}
+	keys := idx.S.GetKeys(lo, hi)
+	return int(lo) + sort.Search(len(keys), func(i int) bool { return after(keys[i]) })
+}
@@ -0,0 +1,93 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskIndex_Range(t *testing.T) {
+	// given
+	mem := store.NewMemBackend(nil)
+	FlushWith(New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98}), mem, store.Options{Layout: store.COMPRESSED})
+	disk, _ := OpenDisk(mem)
+	collect := func(in Interval, limit int) (keys []float64, values []uint64) {
+		disk.Range(in, func(k float64, v uint64) bool {
+			keys, values = append(keys, k), append(values, v)
+			return len(keys) != limit
+		})
+		return keys, values
+	}
+
+	// when
+	keys, values := collect(Interval{From: 3, To: 5}, 0)
+	// then
+	assert.Equal(t, []float64{3, 3, 3.14, 5}, keys)
+	assert.Equal(t, []uint64{1, 2, 3, 0}, values)
+
+	// when the bounds are excluded
+	keys, _ = collect(Interval{From: 3, To: 5, ExcludeFrom: true, ExcludeTo: true}, 0)
+	// then
+	assert.Equal(t, []float64{3.14}, keys)
+
+	// when the bounds are not keys
+	keys, _ = collect(Interval{From: 0, To: 2.99}, 0)
+	// then
+	assert.Equal(t, []float64{2.5, 2.98}, keys)
+
+	// when limited
+	keys, _ = collect(Interval{From: 0, To: 100}, 3)
+	// then
+	assert.Equal(t, []float64{2.5, 2.98, 3}, keys)
+
+	// when empty
+	keys, _ = collect(Interval{From: 11, To: 100}, 0)
+	// then
+	assert.Nil(t, keys)
+}
+
+func TestDiskIndex_Range_Duplicates(t *testing.T) {
+	// given many duplicates, the error window only covers the last one
+	keys := make([]float64, 2000)
+	for i := range keys {
+		keys[i] = float64(i / 500)
+	}
+	mem := store.NewMemBackend(nil)
+	Flush(New(keys), mem)
+	disk, _ := OpenDisk(mem)
+
+	// when
+	n := 0
+	disk.Range(Interval{From: 1, To: 2}, func(k float64, v uint64) bool {
+		n++
+		return true
+	})
+
+	// then
+	assert.Equal(t, 1000, n)
+}
+
+func TestDiskIndex_Range_Delta(t *testing.T) {
+	// given
+	keys := make([]float64, 100)
+	for i := range keys {
+		keys[i] = float64(i)
+	}
+	mem := store.NewMemBackend(nil)
+	Flush(New(keys), mem)
+	disk, _ := OpenDisk(mem)
+	disk.Insert([]float64{41.5, 42, 45, 7}, []uint64{100, 101, 102, 103}, Source{}, store.Options{})
+
+	// when
+	var found []float64
+	var values []uint64
+	disk.Range(Interval{From: 41, To: 45, ExcludeTo: true}, func(k float64, v uint64) bool {
+		found, values = append(found, k), append(values, v)
+		return true
+	})
+
+	// then
+	assert.Equal(t, []float64{41, 41.5, 42, 42, 43, 44}, found)
+	assert.Equal(t, []uint64{41, 100, 42, 101, 43, 44}, values)
+}
@@ -0,0 +1,122 @@
+package index
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/table"
+)
+
+/*
+Source describes the file indexed by a store. Size, ModTime and Hash are taken when the index is built,
+so that a reader can tell whether the file has changed since
+*/
+type Source struct {
+	Path    string
+	Column  string
+	Size    int64
+	ModTime time.Time
+	Hash    []byte // sha256 of the Size first bytes
+	Format  table.Format
+}
+
+/*
+SourceStatus tells how the source file differs from the one which was indexed
+*/
+type SourceStatus int
+
+const (
+	UNKNOWN  SourceStatus = iota // the index doesn't record the state of its source
+	FRESH                        // the source is unchanged
+	APPENDED                     // rows were appended to the source, the indexed ones are unchanged
+	CHANGED                      // the indexed bytes of the source were modified
+	MISSING                      // the source can't be found
+)
+
+func (s SourceStatus) String() string {
+	return [...]string{"unknown", "fresh", "appended", "changed", "missing"}[s]
+}
+
+/*
+Stale tells if the rows read through the index may differ from the rows of the source
+*/
+func (s SourceStatus) Stale() bool {
+	return s != FRESH && s != UNKNOWN
+}
+
+/*
+StatSource returns the Source of the column of the file at path, with its size, mtime and content hash
+*/
+func StatSource(path, column string) (Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Source{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return Source{}, err
+	}
+	h, err := hashPrefix(f, fi.Size())
+	if err != nil {
+		return Source{}, err
+	}
+	return Source{Path: path, Column: column, Size: fi.Size(), ModTime: fi.ModTime().UTC(), Hash: h}, nil
+}
+
+/*
+Status compares the source file with its state recorded at build time.
+The content is hashed only when the size or the mtime differ, a grown file whose first bytes
+are unchanged is APPENDED
+*/
+func (s Source) Status() (SourceStatus, error) {
+	if s.Hash == nil {
+		return UNKNOWN, nil
+	}
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return MISSING, nil
+	}
+	if err != nil {
+		return UNKNOWN, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return UNKNOWN, err
+	}
+	if fi.Size() < s.Size {
+		return CHANGED, nil
+	}
+	if fi.Size() == s.Size && fi.ModTime().Equal(s.ModTime) {
+		return FRESH, nil
+	}
+	h, err := hashPrefix(f, s.Size)
+	if err != nil {
+		return UNKNOWN, err
+	}
+	switch {
+	case !bytes.Equal(h, s.Hash):
+		return CHANGED, nil
+	case fi.Size() > s.Size:
+		return APPENDED, nil
+	}
+	return FRESH, nil
+}
+
+// hashPrefix returns the sha256 of the n first bytes of r
+func hashPrefix(r io.ReaderAt, n int64) ([]byte, error) {
+	h := sha256.New()
+	written, err := io.Copy(h, io.NewSectionReader(r, 0, n))
+	if err != nil {
+		return nil, err
+	}
+	if written != n {
+		return nil, fmt.Errorf("read %d bytes out of %d", written, n)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,73 @@
+package index
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceStatus(t *testing.T) {
+	// given
+	path := filepath.Join(t.TempDir(), "people.csv")
+	ioutil.WriteFile(path, []byte("name,age\njeanne,90\n"), 0644)
+	source, err := StatSource(path, "age")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(19), source.Size)
+
+	// when unchanged
+	status, err := source.Status()
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, FRESH, status)
+	assert.False(t, status.Stale())
+
+	// when only touched
+	later := source.ModTime.Add(time.Hour)
+	os.Chtimes(path, later, later)
+	status, _ = source.Status()
+	// then
+	assert.Equal(t, FRESH, status)
+
+	// when a row is appended
+	ioutil.WriteFile(path, []byte("name,age\njeanne,90\njean,23\n"), 0644)
+	status, _ = source.Status()
+	// then
+	assert.Equal(t, APPENDED, status)
+	assert.True(t, status.Stale())
+
+	// when an indexed row is modified
+	ioutil.WriteFile(path, []byte("name,age\njeanne,91\njean,23\n"), 0644)
+	status, _ = source.Status()
+	// then
+	assert.Equal(t, CHANGED, status)
+
+	// when truncated
+	ioutil.WriteFile(path, []byte("name,age\n"), 0644)
+	status, _ = source.Status()
+	// then
+	assert.Equal(t, CHANGED, status)
+
+	// when removed
+	os.Remove(path)
+	status, _ = source.Status()
+	// then
+	assert.Equal(t, MISSING, status)
+	assert.Equal(t, "missing", status.String())
+}
+
+func TestSourceStatus_Unknown(t *testing.T) {
+	// given an index written before the source stat was recorded
+	source := Source{Path: "data/people.csv", Column: "age"}
+
+	// when
+	status, err := source.Status()
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, UNKNOWN, status)
+	assert.False(t, status.Stale())
+}
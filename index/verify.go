@@ -0,0 +1,85 @@
+package index
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/BenJoyenConseil/rmi/store"
+)
+
+const (
+	VERIFY_CHUNK = 1 << 16 // keys read at once by Verify
+)
+
+/*
+Verify reads the whole store and returns the problems found, none when the index is sound:
+the record count of the header must match the model, the checksums recorded in the meta section must match the records and the delta,
+the keys must be sorted, and every key must be reachable inside the error window that GuessIndex returns for it
+*/
+func (idx *DiskIndex) Verify() (problems []error) {
+	defer func() {
+		// the store panics when a read fails, a truncated file for example
+		if r := recover(); r != nil {
+			problems = append(problems, fmt.Errorf("the store can't be read: %v", r))
+		}
+	}()
+	if n := idx.S.RecordCount(); n != int64(idx.Len) {
+		problems = append(problems, fmt.Errorf("the header counts %d records but the model was fitted over %d keys", n, idx.Len))
+		return problems
+	}
+	if idx.Checksummed {
+		if crc, err := idx.S.RecordsChecksum(); err != nil {
+			problems = append(problems, err)
+		} else if crc != idx.Checksum {
+			problems = append(problems, fmt.Errorf("the checksum of the records is %08x, %08x was recorded", crc, idx.Checksum))
+		}
+		if crc := store.Checksum(idx.delta.bytes()); crc != idx.DeltaChecksum {
+			problems = append(problems, fmt.Errorf("the checksum of the delta is %08x, %08x was recorded", crc, idx.DeltaChecksum))
+		}
+	}
+	problems = append(problems, idx.verifyKeys()...)
+	for i := 1; i < len(idx.delta.Keys); i++ {
+		if !(idx.delta.Keys[i-1] <= idx.delta.Keys[i]) {
+			problems = append(problems, fmt.Errorf("the delta keys %d and %d are not sorted: %v > %v", i-1, i, idx.delta.Keys[i-1], idx.delta.Keys[i]))
+		}
+	}
+	return problems
+}
+
+// verifyKeys streams the keys of the store by chunks, checking their order and that each run of equal keys
+// overlaps the error window of the key
+func (idx *DiskIndex) verifyKeys() (problems []error) {
+	first, prev := 0, math.NaN()
+	run := func(last int) {
+		if _, lower, upper := idx.GuessIndex(prev); upper < first || lower > last {
+			problems = append(problems, fmt.Errorf("the key %v at [%d, %d] is out of its error window [%d, %d]", prev, first, last, lower, upper))
+		}
+	}
+	for lo := 0; lo < idx.Len; lo += VERIFY_CHUNK {
+		hi := lo + VERIFY_CHUNK - 1
+		if hi > idx.Len-1 {
+			hi = idx.Len - 1
+		}
+		for i, key := range idx.S.GetKeys(int64(lo), int64(hi)) {
+			pos := lo + i
+			switch {
+			case math.IsNaN(key):
+				problems = append(problems, fmt.Errorf("the key at %d is NaN", pos))
+				continue
+			case math.IsNaN(prev):
+			case key == prev:
+				continue
+			case key < prev:
+				problems = append(problems, fmt.Errorf("the keys %d and %d are not sorted: %v > %v", pos-1, pos, prev, key))
+				fallthrough
+			default:
+				run(pos - 1)
+			}
+			first, prev = pos, key
+		}
+	}
+	if idx.Len > 0 && !math.IsNaN(prev) {
+		run(idx.Len - 1)
+	}
+	return problems
+}
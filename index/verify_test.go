@@ -0,0 +1,91 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/estimate/linear"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	for _, layout := range []store.Layout{store.INTERLEAVED, store.COLUMNAR, store.COMPRESSED} {
+		// given
+		mem := store.NewMemBackend(nil)
+		FlushWith(New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98}), mem, store.Options{Layout: layout})
+		disk, _ := OpenDisk(mem)
+
+		// when
+		problems := disk.Verify()
+
+		// then
+		assert.True(t, disk.Checksummed, layout)
+		assert.Empty(t, problems, layout)
+	}
+}
+
+func TestVerify_Delta(t *testing.T) {
+	// given
+	keys := make([]float64, 100)
+	for i := range keys {
+		keys[i] = float64(i)
+	}
+	mem := store.NewMemBackend(nil)
+	Flush(New(keys), mem)
+	disk, _ := OpenDisk(mem)
+	disk.Insert([]float64{42.5, 7}, []uint64{100, 101}, Source{}, store.Options{})
+	disk, _ = OpenDisk(mem)
+
+	// when
+	problems := disk.Verify()
+
+	// then
+	assert.Equal(t, 2, disk.DeltaLen)
+	assert.Empty(t, problems)
+
+	// when the delta is corrupted
+	mem.WriteAt([]byte{0xff}, disk.DeltaOffset)
+	disk, _ = OpenDisk(mem)
+	problems = disk.Verify()
+	// then
+	assert.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Error(), "checksum of the delta")
+}
+
+func TestVerify_Corrupted(t *testing.T) {
+	// given
+	mem := store.NewMemBackend(nil)
+	Flush(New([]float64{5, 3, 3, 3.14, 10, 2.5, 2.98}), mem)
+
+	// when the last key, 10, is overwritten by 1
+	mem.WriteAt(store.ToRecord(1, 4), int64(len(mem.Bytes()))-store.RECORD_LEN)
+	disk, _ := OpenDisk(mem)
+	problems := disk.Verify()
+
+	// then
+	assert.Len(t, problems, 3)
+	assert.Contains(t, problems[0].Error(), "checksum of the records")
+	assert.Contains(t, problems[1].Error(), "not sorted")
+	assert.Contains(t, problems[2].Error(), "out of its error window")
+
+	// when the file is truncated
+	mem.Truncate(int64(len(mem.Bytes())) - store.RECORD_LEN)
+	problems = disk.Verify()
+	// then
+	assert.NotEmpty(t, problems)
+}
+
+func TestVerify_OutOfBounds(t *testing.T) {
+	// given a model whose error bounds don't cover the keys
+	mem := store.NewMemBackend(nil)
+	idx := New([]float64{1, 2, 3, 4, 100})
+	idx.M, idx.MinErrBound, idx.MaxErrBound = &linear.RegressionModel{}, 0, 0
+	Flush(idx, mem)
+	disk, _ := OpenDisk(mem)
+
+	// when
+	problems := disk.Verify()
+
+	// then all the keys are guessed at position 0
+	assert.Len(t, problems, 4)
+}
@@ -0,0 +1,143 @@
+package kv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
+)
+
+const (
+	VALUE_LOG    = "values.log"
+	ENTRY_HEADER = 12 // key + value length, written before each value of the log
+)
+
+/*
+DB is an embedded key-value store whose values are byte strings of any length, up to table.MAX_LENGTH.
+The values are appended to a value log, an LSMIndex maps each key to the table.Pointer of its latest value.
+A replaced or deleted value stays in the log, DB suits read-mostly reference data.
+The writes are durable once Flush or Close returns
+*/
+type DB struct {
+	idx *index.LSMIndex
+	log store.Backend
+	mu  sync.Mutex // serializes the appends to the log
+	end int64
+}
+
+/*
+Open opens the DB stored in dir, its segments are written with opts
+*/
+func Open(dir string, opts store.Options) (*DB, error) {
+	idx, err := index.OpenLSM(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, VALUE_LOG), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		idx.Close()
+		return nil, err
+	}
+	db := &DB{idx: idx, log: store.NewFileBackend(f)}
+	if db.end, err = db.log.Size(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+/*
+Put appends value to the log and maps the key to it
+*/
+func (db *DB) Put(key float64, value []byte) error {
+	if len(value) > table.MAX_LENGTH {
+		return fmt.Errorf("the value of %d bytes is larger than %d bytes", len(value), table.MAX_LENGTH)
+	}
+	entry := make([]byte, ENTRY_HEADER+len(value))
+	binary.LittleEndian.PutUint64(entry, math.Float64bits(key))
+	binary.LittleEndian.PutUint32(entry[8:], uint32(len(value)))
+	copy(entry[ENTRY_HEADER:], value)
+
+	db.mu.Lock()
+	off := db.end
+	if _, err := db.log.WriteAt(entry, off); err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	db.end += int64(len(entry))
+	db.mu.Unlock()
+
+	p, err := table.NewPointer(off+ENTRY_HEADER, int64(len(value)))
+	if err != nil {
+		return err
+	}
+	return db.idx.Put(key, uint64(p))
+}
+
+/*
+Get returns the latest value of the key, found is false when the key was never written or was deleted
+*/
+func (db *DB) Get(key float64) (value []byte, found bool, err error) {
+	p, found, err := db.idx.Get(key)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	value, err = table.Pointer(p).ReadAt(db.log)
+	return value, err == nil, err
+}
+
+/*
+Delete removes the key
+*/
+func (db *DB) Delete(key float64) error {
+	return db.idx.Delete(key)
+}
+
+/*
+Scan calls fn with the keys between lo and hi (both included) in ascending order, and their latest value,
+until fn returns false
+*/
+func (db *DB) Scan(lo, hi float64, fn func(key float64, value []byte) bool) error {
+	var err error
+	scanErr := db.idx.Scan(lo, hi, func(key float64, p uint64) bool {
+		var value []byte
+		if value, err = table.Pointer(p).ReadAt(db.log); err != nil {
+			return false
+		}
+		return fn(key, value)
+	})
+	if scanErr != nil {
+		return scanErr
+	}
+	return err
+}
+
+/*
+Flush syncs the value log then writes the memtable of the index to a segment
+*/
+func (db *DB) Flush() error {
+	if err := db.log.Sync(); err != nil {
+		return err
+	}
+	return db.idx.Flush()
+}
+
+/*
+Close flushes the DB and closes its files
+*/
+func (db *DB) Close() error {
+	err := db.log.Sync()
+	if cerr := db.idx.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := db.log.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
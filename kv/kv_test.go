@@ -0,0 +1,106 @@
+package kv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutGet(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	db, err := Open(dir, store.Options{})
+	assert.NoError(t, err)
+
+	// when
+	assert.NoError(t, db.Put(23, []byte("jean,23,M")))
+	assert.NoError(t, db.Put(90, []byte("jeanne,90,F")))
+	assert.NoError(t, db.Put(23, []byte("Georgette,23,F")))
+	assert.NoError(t, db.Put(0, []byte{}))
+	assert.NoError(t, db.Delete(90))
+
+	// then
+	v, found, err := db.Get(23)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("Georgette,23,F"), v)
+	_, found, _ = db.Get(90)
+	assert.False(t, found)
+	v, found, _ = db.Get(0)
+	assert.True(t, found)
+	assert.Empty(t, v)
+
+	// when opened again
+	assert.NoError(t, db.Close())
+	db, err = Open(dir, store.Options{})
+
+	// then
+	assert.NoError(t, err)
+	v, found, _ = db.Get(23)
+	assert.True(t, found)
+	assert.Equal(t, []byte("Georgette,23,F"), v)
+	_, found, _ = db.Get(90)
+	assert.False(t, found)
+	assert.NoError(t, db.Close())
+}
+
+func TestScan(t *testing.T) {
+	// given
+	db, _ := Open(t.TempDir(), store.Options{Layout: store.COMPRESSED})
+	defer db.Close()
+	for i := 0; i < 10000; i++ {
+		db.Put(float64(i), []byte(fmt.Sprintf("value %d", i)))
+	}
+	db.Delete(5002)
+
+	// when
+	var keys []float64
+	var values []string
+	err := db.Scan(5000, 5004.5, func(k float64, v []byte) bool {
+		keys, values = append(keys, k), append(values, string(v))
+		return true
+	})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{5000, 5001, 5003, 5004}, keys)
+	assert.Equal(t, []string{"value 5000", "value 5001", "value 5003", "value 5004"}, values)
+}
+
+func TestPut_Errors(t *testing.T) {
+	// given
+	db, _ := Open(t.TempDir(), store.Options{})
+	defer db.Close()
+
+	// when
+	err := db.Put(1, make([]byte, 1<<24))
+
+	// then
+	assert.Error(t, err)
+}
+
+func ExampleDB() {
+	dir, _ := ioutil.TempDir("", "kv")
+	defer os.RemoveAll(dir)
+	db, _ := Open(dir, store.Options{})
+	defer db.Close()
+
+	db.Put(75001, []byte("Paris 1er"))
+	db.Put(69001, []byte("Lyon 1er"))
+	db.Put(13001, []byte("Marseille 1er"))
+	v, _, _ := db.Get(69001)
+	fmt.Println(string(v))
+	db.Scan(60000, 80000, func(k float64, v []byte) bool {
+		fmt.Println(k, string(v))
+		return true
+	})
+
+	// Output:
+	// Lyon 1er
+	// 69001 Lyon 1er
+	// 75001 Paris 1er
+}
@@ -5,10 +5,11 @@ import (
 	"sort"
 )
 
+/*
+BinarySearchLookup searches the first occurrence of the key in st, sorted as NewSortedTable returns it,
+then collects the offsets of all its occurrences
+*/
 func BinarySearchLookup(key float64, st *SortedTable) (offsets []int, err error) {
-	if !sort.SliceIsSorted(st.Keys, func(i, j int) bool { return st.Keys[i] < st.Keys[j] }) {
-		panic("not sorted!!")
-	}
 	i := sort.SearchFloat64s(st.Keys, key)
 	for ; i < len(st.Keys); i++ {
 		if st.Keys[i] > key {
@@ -22,7 +22,8 @@ func (st byKeys) Swap(i, j int) {
 func (st byKeys) Less(i, j int) bool { return st.Keys[i] < st.Keys[j] }
 
 /*
-Return a Sorted Table structure sorted by key in an ascending order
+Return a Sorted Table structure sorted by key in an ascending order.
+The sort is stable: the offsets of equal keys stay in ascending order
 */
 func NewSortedTable(x []float64) *SortedTable {
 	keys, offsets := x, make([]int, len(x))
@@ -30,6 +31,32 @@ func NewSortedTable(x []float64) *SortedTable {
 		offsets[i] = i
 	}
 	st := &SortedTable{Keys: keys, Offsets: offsets}
-	sort.Sort(byKeys{st})
+	sort.Stable(byKeys{st})
 	return st
 }
+
+/*
+A PostingTable holds each distinct key once, sorted in an ascending order,
+with the list of its offsets in ascending order
+*/
+type PostingTable struct {
+	Keys     []float64
+	Postings [][]int
+}
+
+/*
+Return a Posting Table grouping the offsets of the equal keys of x
+*/
+func NewPostingTable(x []float64) *PostingTable {
+	st := NewSortedTable(append([]float64{}, x...))
+	pt := &PostingTable{}
+	for i, k := range st.Keys {
+		if n := len(pt.Keys); n > 0 && pt.Keys[n-1] == k {
+			pt.Postings[n-1] = append(pt.Postings[n-1], st.Offsets[i])
+			continue
+		}
+		pt.Keys = append(pt.Keys, k)
+		pt.Postings = append(pt.Postings, []int{st.Offsets[i]})
+	}
+	return pt
+}
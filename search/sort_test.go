@@ -17,3 +17,35 @@ func TestNewSortedTable(t *testing.T) {
 	assert.Equal(t, []float64{2.5, 2.98, 3, 3, 3.14, 5, 10}, st.Keys)
 	assert.Equal(t, []int{5, 6, 1, 2, 3, 0, 4}, st.Offsets)
 }
+
+func TestNewSortedTable_Stable(t *testing.T) {
+	// given more duplicates than the insertion sort threshold of sort.Sort
+	keys := make([]float64, 100)
+	for i := range keys {
+		keys[i] = float64(i % 3)
+	}
+
+	// when
+	st := NewSortedTable(keys)
+
+	// then
+	for i := 1; i < len(keys); i++ {
+		if st.Keys[i] == st.Keys[i-1] {
+			assert.Less(t, st.Offsets[i-1], st.Offsets[i])
+		}
+	}
+}
+
+func TestNewPostingTable(t *testing.T) {
+	// given
+	keys := []float64{5, 3, 3, 3.14, 10, 2.5, 3, 2.98}
+
+	// when
+	pt := NewPostingTable(keys)
+
+	// then
+	assert.Equal(t, []float64{2.5, 2.98, 3, 3.14, 5, 10}, pt.Keys)
+	assert.Equal(t, [][]int{{5}, {7}, {1, 2, 6}, {3}, {0}, {4}}, pt.Postings)
+	// the dataset is left untouched
+	assert.Equal(t, []float64{5, 3, 3, 3.14, 10, 2.5, 3, 2.98}, keys)
+}
@@ -0,0 +1,61 @@
+package search
+
+import "fmt"
+
+const (
+	TREE_FANOUT = 16 // children of a node, and keys of a leaf
+)
+
+/*
+Tree is a static B+tree over the keys of a SortedTable: the leaves are the blocks of TREE_FANOUT keys of the table,
+each level above holds the first key of every node of the level below
+*/
+type Tree struct {
+	ST     *SortedTable
+	levels [][]float64 // from the root to the level of the leaves
+}
+
+/*
+NewTree builds the levels of the tree from the bottom, until the root fits in a single node
+*/
+func NewTree(st *SortedTable) *Tree {
+	t := &Tree{ST: st}
+	for level := st.Keys; len(level) > TREE_FANOUT; {
+		up := make([]float64, 0, (len(level)+TREE_FANOUT-1)/TREE_FANOUT)
+		for i := 0; i < len(level); i += TREE_FANOUT {
+			up = append(up, level[i])
+		}
+		t.levels = append([][]float64{up}, t.levels...)
+		level = up
+	}
+	return t
+}
+
+/*
+Lookup descends from the root to the leaf which may hold the first occurrence of the key,
+then returns the offsets of all its occurrences
+*/
+func (t *Tree) Lookup(key float64) (offsets []int, err error) {
+	// the child holding the first key >= key is the last one starting with a key < key
+	node := 0
+	for _, level := range t.levels {
+		first, end := node*TREE_FANOUT, node*TREE_FANOUT+TREE_FANOUT
+		if end > len(level) {
+			end = len(level)
+		}
+		node = first
+		for c := first + 1; c < end && level[c] < key; c++ {
+			node = c
+		}
+	}
+	i := node * TREE_FANOUT
+	for ; i < len(t.ST.Keys) && t.ST.Keys[i] < key; i++ {
+	}
+	for ; i < len(t.ST.Keys) && t.ST.Keys[i] == key; i++ {
+		offsets = append(offsets, t.ST.Offsets[i])
+	}
+	if len(offsets) > 0 {
+		return offsets, nil
+	}
+	return nil, fmt.Errorf("The following key <%f> is not found in the index", key)
+}
@@ -0,0 +1,50 @@
+package search
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTree(t *testing.T) {
+	// given
+	st := &SortedTable{
+		Keys:    []float64{.2342, 1.234, 2., 2., 3., 3., 10., 28},
+		Offsets: []int{3, 2, 1, 4, 6, 0, 7, 5},
+	}
+	tree := NewTree(st)
+
+	// when
+	o, err := tree.Lookup(3.)
+	// then
+	assert.Equal(t, []int{6, 0}, o)
+	assert.NoError(t, err)
+
+	// when
+	o, err = tree.Lookup(4.)
+	// then
+	assert.Nil(t, o)
+	assert.Error(t, err)
+}
+
+func TestTree_Levels(t *testing.T) {
+	// given runs of duplicates crossing the leaves of a 3 levels tree
+	keys := make([]float64, 5000)
+	for i := range keys {
+		keys[i] = float64(rand.Intn(300))
+	}
+	st := NewSortedTable(append([]float64{}, keys...))
+	tree := NewTree(st)
+
+	for k := -1.; k <= 300; k++ {
+		// when
+		o, err := tree.Lookup(k)
+		expected, errExpected := FullScanLookup(k, st)
+
+		// then
+		assert.Equal(t, expected, o, k)
+		assert.Equal(t, errExpected, err, k)
+	}
+	assert.Len(t, tree.levels, 3)
+}
@@ -0,0 +1,371 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BenJoyenConseil/rmi/catalog"
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
+)
+
+const (
+	RANGE_LIMIT = 1000  // matches returned by a range without limit parameter
+	MAX_BATCH   = 10000 // keys of a batched lookup
+)
+
+/*
+Match is a record found by a lookup or a range: its key, its value and the row it points to in the source
+*/
+type Match struct {
+	Key   float64 `json:"key"`
+	Value uint64  `json:"value"`         // the pointer to the row, or its position without source
+	Row   string  `json:"row,omitempty"` // the raw bytes of the row, without its line terminator
+}
+
+/*
+LookupResult holds the matches of a key
+*/
+type LookupResult struct {
+	Key     float64 `json:"key"`
+	Found   bool    `json:"found"`
+	Matches []Match `json:"matches"`
+}
+
+/*
+RangeResult holds the matches of the keys between From and To, More tells if the limit stopped the range
+*/
+type RangeResult struct {
+	From    float64 `json:"from"`
+	To      float64 `json:"to"`
+	Matches []Match `json:"matches"`
+	More    bool    `json:"more"`
+}
+
+/*
+Stats describes a served index and counts the queries it answered.
+Source tells how the source CSV differs from the indexed one, Stale if the rows served may differ from it
+*/
+type Stats struct {
+	Name    string       `json:"name"`
+	Report  index.Report `json:"report"`
+	Source  string       `json:"source"`
+	Stale   bool         `json:"stale"`
+	Lookups uint64       `json:"lookups"`
+	Ranges  uint64       `json:"ranges"`
+}
+
+// BatchRequest is the body of a POST on /indexes/{name}/lookup
+type BatchRequest struct {
+	Keys []float64 `json:"keys"`
+}
+
+/*
+Server answers the queries over the indexes of a catalog, opened once when it is created.
+GET /indexes lists them, then for each of them:
+GET /indexes/{name}/lookup?key=, POST /indexes/{name}/lookup with a BatchRequest,
+GET /indexes/{name}/range?from=&to=[&limit=][&inclusive_from=false][&exclusive_to=true] and GET /indexes/{name}/stats
+*/
+type Server struct {
+	indexes map[string]*served
+}
+
+// served is an index with its files kept open, the lookups read them concurrently with ReadAt
+type served struct {
+	name            string
+	idx             *index.DiskIndex
+	file, source    *os.File
+	report          index.Report
+	lookups, ranges uint64
+
+	mu     sync.Mutex
+	status sourceStatus // the last status of the source, hashed again only when its file changes
+}
+
+// sourceStatus is the status of the source computed when its file had this size and this mtime
+type sourceStatus struct {
+	known   bool
+	size    int64
+	modTime time.Time
+	status  index.SourceStatus
+}
+
+/*
+CheckSource is called with the path of each index and the source it was built from when the server opens it,
+the server isn't created when it returns an error
+*/
+type CheckSource func(path string, source index.Source) error
+
+/*
+New opens every index of the catalog, and their source to read the rows. check may be nil
+*/
+func New(cat *catalog.Catalog, check CheckSource) (*Server, error) {
+	s := &Server{indexes: map[string]*served{}}
+	for _, e := range cat.Entries {
+		sv, err := open(e.Name, cat.Path(e), check)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("%s: %s", e.Name, err)
+		}
+		s.indexes[e.Name] = sv
+	}
+	return s, nil
+}
+
+func open(name, path string, check CheckSource) (*served, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sv := &served{name: name, file: f}
+	if sv.idx, err = index.OpenDisk(store.NewFileBackend(f)); err == nil {
+		sv.report, err = sv.idx.Inspect()
+	}
+	if err == nil && check != nil {
+		err = check(path, sv.idx.Source)
+	}
+	if err == nil && sv.idx.Values == index.POINTERS {
+		sv.source, err = os.Open(sv.idx.Source.Path)
+	}
+	if err != nil {
+		sv.close()
+		return nil, err
+	}
+	return sv, nil
+}
+
+/*
+Close closes the files of the indexes
+*/
+func (s *Server) Close() error {
+	for _, sv := range s.indexes {
+		sv.close()
+	}
+	return nil
+}
+
+func (sv *served) close() {
+	sv.file.Close()
+	if sv.source != nil {
+		sv.source.Close()
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "indexes" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route for %s", r.URL.Path))
+		return
+	}
+	if len(parts) == 1 {
+		names := []string{}
+		for name := range s.indexes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeJSON(w, http.StatusOK, names)
+		return
+	}
+	sv, ok := s.indexes[parts[1]]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no index named %q", parts[1]))
+		return
+	}
+	op := ""
+	if len(parts) == 3 {
+		op = parts[2]
+	}
+	switch {
+	case op == "lookup" && r.Method == http.MethodGet:
+		key, err := floatParam(r, "key")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := sv.lookup(key)
+		respond(w, result, err)
+	case op == "lookup" && r.Method == http.MethodPost:
+		var batch BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("the body must be a JSON object {\"keys\": [...]}: %s", err))
+			return
+		}
+		if len(batch.Keys) > MAX_BATCH {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%d keys in the batch, %d at most", len(batch.Keys), MAX_BATCH))
+			return
+		}
+		results := make([]LookupResult, len(batch.Keys))
+		for i, key := range batch.Keys {
+			var err error
+			if results[i], err = sv.lookup(key); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, results)
+	case op == "range" && r.Method == http.MethodGet:
+		in, limit, err := rangeParams(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := sv.between(in, limit)
+		respond(w, result, err)
+	case op == "stats" && r.Method == http.MethodGet:
+		status, err := sv.sourceStatus()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, Stats{Name: sv.name, Report: sv.report, Source: status.String(), Stale: status.Stale(),
+			Lookups: atomic.LoadUint64(&sv.lookups), Ranges: atomic.LoadUint64(&sv.ranges)})
+	case op == "lookup" || op == "range" || op == "stats":
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not allowed on %s", r.Method, r.URL.Path))
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route for %s", r.URL.Path))
+	}
+}
+
+func (sv *served) lookup(key float64) (LookupResult, error) {
+	atomic.AddUint64(&sv.lookups, 1)
+	result := LookupResult{Key: key, Matches: []Match{}}
+	b, err := sv.idx.Postings(key)
+	if err != nil {
+		// a miss
+		return result, nil
+	}
+	for _, v := range b.ToArray() {
+		m, err := sv.match(key, v)
+		if err != nil {
+			return result, err
+		}
+		result.Matches = append(result.Matches, m)
+	}
+	result.Found = true
+	return result, nil
+}
+
+func (sv *served) between(in index.Interval, limit int) (RangeResult, error) {
+	atomic.AddUint64(&sv.ranges, 1)
+	result := RangeResult{From: in.From, To: in.To, Matches: []Match{}}
+	var err error
+	sv.idx.Range(in, func(key float64, value uint64) bool {
+		if len(result.Matches) == limit {
+			result.More = true
+			return false
+		}
+		var m Match
+		if m, err = sv.match(key, value); err != nil {
+			return false
+		}
+		result.Matches = append(result.Matches, m)
+		return true
+	})
+	return result, err
+}
+
+// sourceStatus returns the status of the source, computed again only when the size or the mtime of its file change
+func (sv *served) sourceStatus() (index.SourceStatus, error) {
+	var size int64
+	var modTime time.Time
+	fi, err := os.Stat(sv.idx.Source.Path)
+	if err == nil {
+		size, modTime = fi.Size(), fi.ModTime()
+	} else if !os.IsNotExist(err) {
+		return index.UNKNOWN, err
+	}
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.status.known && sv.status.size == size && sv.status.modTime.Equal(modTime) {
+		return sv.status.status, nil
+	}
+	status, err := sv.idx.Source.Status()
+	if err != nil {
+		return status, err
+	}
+	sv.status = sourceStatus{known: true, size: size, modTime: modTime, status: status}
+	return status, nil
+}
+
+// match reads the row pointed by the value when the index has a source
+func (sv *served) match(key float64, value uint64) (Match, error) {
+	m := Match{Key: key, Value: value}
+	if sv.source != nil {
+		row, err := table.Pointer(value).ReadAt(sv.source)
+		if err != nil {
+			return m, err
+		}
+		m.Row = string(row)
+	}
+	return m, nil
+}
+
+func rangeParams(r *http.Request) (in index.Interval, limit int, err error) {
+	if in.From, err = floatParam(r, "from"); err != nil {
+		return in, 0, err
+	}
+	if in.To, err = floatParam(r, "to"); err != nil {
+		return in, 0, err
+	}
+	if in.From > in.To {
+		return in, 0, fmt.Errorf("from %v is greater than to %v", in.From, in.To)
+	}
+	limit = RANGE_LIMIT
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			return in, 0, fmt.Errorf("the limit %q is not a positive integer", l)
+		}
+	}
+	q := r.URL.Query()
+	in.ExcludeFrom, in.ExcludeTo = q.Get("inclusive_from") == "false", q.Get("exclusive_to") == "true"
+	return in, limit, nil
+}
+
+// floatParam parses the query parameter, which is required and must be a finite number
+func floatParam(r *http.Request, name string) (float64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, fmt.Errorf("the parameter %s is missing", name)
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("the parameter %s=%q is not a number", name, v)
+	}
+	return f, nil
+}
+
+func respond(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeJSON encodes v before writing the status, so that a value which can't be encoded is answered with a 500
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(v); err != nil {
+		status = http.StatusInternalServerError
+		b.Reset()
+		json.NewEncoder(&b).Encode(map[string]string{"error": err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+}
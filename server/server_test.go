@@ -0,0 +1,250 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BenJoyenConseil/rmi/catalog"
+	"github.com/BenJoyenConseil/rmi/index"
+	"github.com/BenJoyenConseil/rmi/store"
+	"github.com/BenJoyenConseil/rmi/table"
+	"github.com/stretchr/testify/assert"
+)
+
+const PEOPLE = "name,age\nbob,30\nalice,23\neve,30\njoe,54\nann,7\n"
+
+// newCatalog indexes the age column of PEOPLE in a new catalog, it returns the catalog and the path of the CSV
+func newCatalog(t *testing.T) (*catalog.Catalog, string) {
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "people.csv")
+	ioutil.WriteFile(csv, []byte(PEOPLE), 0644)
+	f, _ := os.Open(csv)
+	defer f.Close()
+	format := table.DefaultFormat()
+	s := format.NewScanner(f)
+	field, column, _ := format.Locate(s, "age")
+	col, _ := format.ScanColumn(s, field, 0)
+	source, _ := index.StatSource(csv, column)
+	source.Format = format
+
+	cat, _ := catalog.Open(dir)
+	out, _ := os.Create(filepath.Join(dir, "people.age.rmi"))
+	defer out.Close()
+	index.FlushPointers(index.New(col.Keys), store.NewFileBackend(out), store.Options{}, source, col.Pointers)
+	disk, _ := index.OpenDisk(store.NewFileBackend(out))
+	e, _ := catalog.Describe("people.age", disk)
+	cat.Add(e)
+	return cat, csv
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	cat, _ := newCatalog(t)
+	srv, err := New(cat, nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() { srv.Close() })
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func get(t *testing.T, url string, v interface{}) int {
+	resp, err := http.Get(url)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	json.NewDecoder(resp.Body).Decode(v)
+	return resp.StatusCode
+}
+
+func rows(matches []Match) (rows []string) {
+	for _, m := range matches {
+		rows = append(rows, m.Row)
+	}
+	return rows
+}
+
+func TestServer_Lookup(t *testing.T) {
+	// given
+	ts := newServer(t)
+
+	// when
+	var found, missing LookupResult
+	status := get(t, ts.URL+"/indexes/people.age/lookup?key=30", &found)
+	get(t, ts.URL+"/indexes/people.age/lookup?key=31", &missing)
+
+	// then
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, found.Found)
+	assert.ElementsMatch(t, []string{"bob,30", "eve,30"}, rows(found.Matches))
+	assert.False(t, missing.Found)
+	assert.Empty(t, missing.Matches)
+}
+
+func TestServer_BatchLookup(t *testing.T) {
+	// given
+	ts := newServer(t)
+	body, _ := json.Marshal(BatchRequest{Keys: []float64{54, 1, 7}})
+
+	// when
+	resp, err := http.Post(ts.URL+"/indexes/people.age/lookup", "application/json", bytes.NewReader(body))
+	var results []LookupResult
+	json.NewDecoder(resp.Body).Decode(&results)
+	resp.Body.Close()
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, results, 3)
+	assert.Equal(t, 54., results[0].Key)
+	assert.Equal(t, []string{"joe,54"}, rows(results[0].Matches))
+	assert.False(t, results[1].Found)
+	assert.Equal(t, []string{"ann,7"}, rows(results[2].Matches))
+}
+
+func TestServer_Range(t *testing.T) {
+	// given
+	ts := newServer(t)
+
+	// when
+	var all, limited, exclusive RangeResult
+	get(t, ts.URL+"/indexes/people.age/range?from=7&to=30", &all)
+	get(t, ts.URL+"/indexes/people.age/range?from=7&to=30&limit=2", &limited)
+	get(t, ts.URL+"/indexes/people.age/range?from=7&to=30&inclusive_from=false&exclusive_to=true", &exclusive)
+
+	// then
+	assert.Equal(t, "ann,7", all.Matches[0].Row)
+	assert.Equal(t, "alice,23", all.Matches[1].Row)
+	assert.ElementsMatch(t, []string{"bob,30", "eve,30"}, rows(all.Matches[2:]))
+	assert.False(t, all.More)
+	assert.Equal(t, []string{"ann,7", "alice,23"}, rows(limited.Matches))
+	assert.True(t, limited.More)
+	assert.Equal(t, []string{"alice,23"}, rows(exclusive.Matches))
+}
+
+func TestServer_Stats(t *testing.T) {
+	// given
+	ts := newServer(t)
+	var lookup LookupResult
+	get(t, ts.URL+"/indexes/people.age/lookup?key=30", &lookup)
+
+	// when
+	var stats Stats
+	var names []string
+	get(t, ts.URL+"/indexes/people.age/stats", &stats)
+	get(t, ts.URL+"/indexes", &names)
+
+	// then
+	assert.Equal(t, "people.age", stats.Name)
+	assert.Equal(t, 5, stats.Report.Records)
+	assert.Equal(t, "fresh", stats.Source)
+	assert.False(t, stats.Stale)
+	assert.Equal(t, uint64(1), stats.Lookups)
+	assert.Equal(t, uint64(0), stats.Ranges)
+	assert.Equal(t, []string{"people.age"}, names)
+}
+
+func TestServer_Errors(t *testing.T) {
+	// given
+	ts := newServer(t)
+
+	for url, expected := range map[string]int{
+		"/indexes/people.age/lookup":                     http.StatusBadRequest,
+		"/indexes/people.age/lookup?key=abc":             http.StatusBadRequest,
+		"/indexes/people.age/lookup?key=NaN":             http.StatusBadRequest,
+		"/indexes/people.age/lookup?key=Inf":             http.StatusBadRequest,
+		"/indexes/people.age/range?from=-Inf&to=30":      http.StatusBadRequest,
+		"/indexes/people.age/range?from=30&to=7":         http.StatusBadRequest,
+		"/indexes/people.age/range?from=7&to=30&limit=0": http.StatusBadRequest,
+		"/indexes/people.name/lookup?key=30":             http.StatusNotFound,
+		"/indexes/people.age/delete":                     http.StatusNotFound,
+		"/other":                                         http.StatusNotFound,
+	} {
+		// when
+		var e map[string]string
+		status := get(t, ts.URL+url, &e)
+
+		// then
+		assert.Equal(t, expected, status, url)
+		assert.NotEmpty(t, e["error"], url)
+	}
+
+	// when
+	resp, _ := http.Post(ts.URL+"/indexes/people.age/stats", "application/json", nil)
+	// then
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestServer_StaleSource(t *testing.T) {
+	// given a source modified after the index was built
+	cat, csv := newCatalog(t)
+	ioutil.WriteFile(csv, []byte("name,age\nbob,31\nalice,23\neve,30\njoe,54\nann,7\n"), 0644)
+
+	// when the check refuses the stale indexes
+	_, err := New(cat, func(path string, source index.Source) error {
+		status, _ := source.Status()
+		if status.Stale() {
+			return fmt.Errorf("%s has changed", source.Path)
+		}
+		return nil
+	})
+	// then
+	assert.EqualError(t, err, "people.age: "+csv+" has changed")
+
+	// when the index is served anyway
+	srv, err := New(cat, nil)
+	assert.NoError(t, err)
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	var stats Stats
+	get(t, ts.URL+"/indexes/people.age/stats", &stats)
+	// then the stats flag it
+	assert.Equal(t, "changed", stats.Source)
+	assert.True(t, stats.Stale)
+}
+
+func TestServer_StatsSourceAppended(t *testing.T) {
+	// given
+	cat, csv := newCatalog(t)
+	srv, err := New(cat, nil)
+	assert.NoError(t, err)
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	var stats Stats
+	get(t, ts.URL+"/indexes/people.age/stats", &stats)
+	assert.Equal(t, "fresh", stats.Source)
+
+	// when a row is appended to the source
+	f, _ := os.OpenFile(csv, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("max,12\n")
+	f.Close()
+	get(t, ts.URL+"/indexes/people.age/stats", &stats)
+
+	// then the cached status is computed again
+	assert.Equal(t, "appended", stats.Source)
+	assert.True(t, stats.Stale)
+}
+
+func TestWriteJSON_Unsupported(t *testing.T) {
+	// given
+	w := httptest.NewRecorder()
+
+	// when the value can't be encoded
+	writeJSON(w, http.StatusOK, map[string]float64{"key": math.NaN()})
+
+	// then the status is not written before the error
+	var e map[string]string
+	json.NewDecoder(w.Body).Decode(&e)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, e["error"], "unsupported value")
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+/*
+Backend holds the bytes of a store. The readers only need ReadAt and Size, the writers WriteAt and Sync
+*/
+type Backend interface {
+	io.ReaderAt
+	io.WriterAt
+	Size() (int64, error)
+	Sync() error
+	Close() error
+}
+
+/*
+truncater is implemented by the backends which can shrink, Create empties them before writing a new store
+*/
+type truncater interface {
+	Truncate(size int64) error
+}
+
+/*
+FileBackend is a Backend over a file
+*/
+type FileBackend struct {
+	*os.File
+}
+
+/*
+NewFileBackend returns the Backend reading and writing f
+*/
+func NewFileBackend(f *os.File) *FileBackend {
+	return &FileBackend{File: f}
+}
+
+func (b *FileBackend) Size() (int64, error) {
+	fi, err := b.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+/*
+MemBackend is a Backend keeping the store in memory, it grows with the writes
+*/
+type MemBackend struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+/*
+NewMemBackend returns a Backend holding a copy of data, which is empty for a new store
+*/
+func NewMemBackend(data []byte) *MemBackend {
+	return &MemBackend{data: append([]byte(nil), data...)}
+}
+
+func (b *MemBackend) ReadAt(p []byte, off int64) (int, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= int64(len(b.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (b *MemBackend) WriteAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if end := off + int64(len(p)); end > int64(len(b.data)) {
+		b.data = append(b.data, make([]byte, end-int64(len(b.data)))...)
+	}
+	return copy(b.data[off:], p), nil
+}
+
+func (b *MemBackend) Truncate(size int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if size < int64(len(b.data)) {
+		b.data = b.data[:size]
+	} else {
+		b.data = append(b.data, make([]byte, size-int64(len(b.data)))...)
+	}
+	return nil
+}
+
+func (b *MemBackend) Size() (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return int64(len(b.data)), nil
+}
+
+func (b *MemBackend) Sync() error {
+	return nil
+}
+
+func (b *MemBackend) Close() error {
+	return nil
+}
+
+/*
+Bytes returns a copy of the store, to write it somewhere else
+*/
+func (b *MemBackend) Bytes() []byte {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]byte(nil), b.data...)
+}
+
+/*
+ReaderAtBackend is a read-only Backend over any io.ReaderAt, such as a section of a larger file or an HTTP range reader
+*/
+type ReaderAtBackend struct {
+	r    io.ReaderAt
+	size int64
+}
+
+/*
+NewReaderAtBackend returns the read-only Backend of the size bytes of r
+*/
+func NewReaderAtBackend(r io.ReaderAt, size int64) *ReaderAtBackend {
+	return &ReaderAtBackend{r: r, size: size}
+}
+
+func (b *ReaderAtBackend) ReadAt(p []byte, off int64) (int, error) {
+	if off >= b.size {
+		return 0, io.EOF
+	}
+	if off+int64(len(p)) > b.size {
+		n, err := b.r.ReadAt(p[:b.size-off], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return b.r.ReadAt(p, off)
+}
+
+func (b *ReaderAtBackend) WriteAt(p []byte, off int64) (int, error) {
+	return 0, fmt.Errorf("the store is read-only")
+}
+
+func (b *ReaderAtBackend) Size() (int64, error) {
+	return b.size, nil
+}
+
+func (b *ReaderAtBackend) Sync() error {
+	return nil
+}
+
+/*
+Close closes the underlying io.ReaderAt when it is an io.Closer
+*/
+func (b *ReaderAtBackend) Close() error {
+	if c, ok := b.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
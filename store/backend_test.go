@@ -0,0 +1,72 @@
+package store
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemBackend(t *testing.T) {
+	// given
+	b := NewMemBackend(nil)
+
+	// when
+	n, err := b.WriteAt([]byte{1, 2, 3}, 2)
+	size, _ := b.Size()
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, int64(5), size)
+	assert.Equal(t, []byte{0, 0, 1, 2, 3}, b.Bytes())
+
+	// when reading past the end
+	p := make([]byte, 4)
+	n, err = b.ReadAt(p, 3)
+	// then
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte{2, 3, 0, 0}, p)
+
+	// when truncated
+	b.Truncate(1)
+	size, _ = b.Size()
+	// then
+	assert.Equal(t, int64(1), size)
+}
+
+func TestReaderAtBackend(t *testing.T) {
+	// given a store written in memory
+	mem := NewMemBackend(nil)
+	Write(mem, []byte("model"), Options{Layout: COLUMNAR}, []float64{1, 2, 2.5}, []uint64{3, 4, 5})
+	data := mem.Bytes()
+
+	// when opened read-only
+	s, err := Open(NewReaderAtBackend(bytes.NewReader(data), int64(len(data))))
+	meta, _ := s.Meta()
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("model"), meta)
+	assert.Equal(t, []float64{2, 2.5}, s.GetKeys(1, 2))
+	assert.Equal(t, []uint64{4, 5}, s.GetValues(1, 2))
+	_, err = s.WriteAt([]byte{1}, 0)
+	assert.Error(t, err)
+	assert.Error(t, s.SetMeta([]byte("MODEL")))
+}
+
+func TestReaderAtBackend_Size(t *testing.T) {
+	// given the first 3 bytes of a larger reader
+	b := NewReaderAtBackend(bytes.NewReader([]byte{1, 2, 3, 4, 5}), 3)
+
+	// when
+	p := make([]byte, 4)
+	n, err := b.ReadAt(p, 1)
+
+	// then
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte{2, 3, 0, 0}, p)
+}
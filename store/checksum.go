@@ -0,0 +1,50 @@
+package store
+
+import (
+	"hash/crc32"
+)
+
+const (
+	CHECKSUM_CHUNK = 1 << 20 // bytes read at once to checksum the records
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+/*
+Checksum returns the CRC-32 (Castagnoli) of b, the one used to checksum the records of a store
+*/
+func Checksum(b []byte) uint32 {
+	return crc32.Checksum(b, castagnoli)
+}
+
+/*
+RecordsLen returns the length in bytes of the record section, whatever its layout
+*/
+func (s Store) RecordsLen() int64 {
+	switch s.layout {
+	case COLUMNAR:
+		return s.count * RECORD_LEN
+	case COMPRESSED:
+		return int64(len(s.blocks))*8 + s.blocks[len(s.blocks)-1]
+	}
+	return s.RecordCount() * RECORD_LEN
+}
+
+/*
+RecordsChecksum reads the record section by chunks and returns its Checksum.
+The pool is bypassed, so that the bytes are the ones of the backend
+*/
+func (s Store) RecordsChecksum() (uint32, error) {
+	crc := uint32(0)
+	b := make([]byte, CHECKSUM_CHUNK)
+	for off, end := s.offset(), s.offset()+s.RecordsLen(); off < end; off += int64(len(b)) {
+		if end-off < int64(len(b)) {
+			b = b[:end-off]
+		}
+		if _, err := s.ReadAt(b, off); err != nil {
+			return 0, err
+		}
+		crc = crc32.Update(crc, castagnoli, b)
+	}
+	return crc, nil
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordsChecksum(t *testing.T) {
+	for _, layout := range []Layout{INTERLEAVED, COLUMNAR, COMPRESSED} {
+		// given
+		mem := NewMemBackend(nil)
+		s, _ := Write(mem, []byte("model"), Options{Layout: layout, PageSize: 64}, []float64{1, 2, 2.5}, []uint64{3, 4, 5})
+
+		// when
+		crc, err := s.RecordsChecksum()
+
+		// then
+		assert.NoError(t, err, layout)
+		data := mem.Bytes()
+		expected := crc32.Checksum(data[s.offset():s.offset()+s.RecordsLen()], crc32.MakeTable(crc32.Castagnoli))
+		assert.Equal(t, expected, crc, layout)
+		assert.Equal(t, int64(len(data)), s.offset()+s.RecordsLen(), layout)
+
+		// when a record is corrupted
+		mem.WriteAt([]byte{0xff}, s.offset()+1)
+		corrupted, _ := s.RecordsChecksum()
+		// then
+		assert.NotEqual(t, crc, corrupted, layout)
+	}
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/*
+Layout tells how the records are written after the header
+*/
+type Layout uint8
+
+const (
+	INTERLEAVED Layout = iota // key, value, key, value...
+	COLUMNAR                  // all the keys, then all the values
+	COMPRESSED                // blocks of bit-packed keys and values, see encodeBlock
+)
+
+func (l Layout) String() string {
+	switch l {
+	case INTERLEAVED:
+		return "interleaved"
+	case COLUMNAR:
+		return "columnar"
+	case COMPRESSED:
+		return "compressed"
+	}
+	return fmt.Sprintf("Layout(%d)", uint8(l))
+}
+
+/*
+ParseLayout returns the Layout named by s
+*/
+func ParseLayout(s string) (Layout, error) {
+	for _, l := range []Layout{INTERLEAVED, COLUMNAR, COMPRESSED} {
+		if l.String() == s {
+			return l, nil
+		}
+	}
+	return INTERLEAVED, fmt.Errorf("unknown layout %q", s)
+}
+
+/*
+Options of a store file written by Write
+*/
+type Options struct {
+	PageSize int64 // align the records on pages of this size, 0 to disable
+	Layout   Layout
+}
+
+/*
+Write creates a store holding meta and the sorted keys with their values, all at once.
+A COLUMNAR store writes the keys contiguously so that a search over them touches only key bytes,
+the value section starts right after the last key.
+A COMPRESSED store writes blocks of BLOCK_LEN records, decoded one by one by the readers
+*/
+func Write(f Backend, meta []byte, opts Options, keys []float64, values []uint64) (Store, error) {
+	if len(keys) != len(values) {
+		return Store{}, fmt.Errorf("%d keys but %d values", len(keys), len(values))
+	}
+	if opts.Layout > COMPRESSED {
+		return Store{}, fmt.Errorf("unknown layout %s", opts.Layout)
+	}
+	word := uint64(len(keys)) | uint64(opts.Layout)<<LAYOUT_SHIFT
+	s, err := create(f, meta, opts.PageSize, word)
+	if err != nil {
+		return s, err
+	}
+	n := int64(len(keys))
+	b := make([]byte, n*RECORD_LEN)
+	if opts.Layout == COMPRESSED {
+		b = encodeCompressed(keys, values)
+	}
+	for i := 0; opts.Layout != COMPRESSED && i < len(keys); i++ {
+		k, v := math.Float64bits(keys[i]), values[i]
+		if opts.Layout == COLUMNAR {
+			binary.LittleEndian.PutUint64(b[int64(i)*KEY_LEN:], k)
+			binary.LittleEndian.PutUint64(b[n*KEY_LEN+int64(i)*VALUE_LEN:], v)
+		} else {
+			binary.LittleEndian.PutUint64(b[int64(i)*RECORD_LEN:], k)
+			binary.LittleEndian.PutUint64(b[int64(i)*RECORD_LEN+KEY_LEN:], v)
+		}
+	}
+	if _, err := f.WriteAt(b, s.dataOffset); err != nil {
+		return s, err
+	}
+	return Open(f)
+}
+
+/*
+Layout returns how the records are written in the store file
+*/
+func (s Store) Layout() Layout {
+	return s.layout
+}
+
+/*
+GetKeys reads the keys from position lower to upper (both included)
+*/
+func (s Store) GetKeys(lower, upper int64) []float64 {
+	if s.layout == COMPRESSED {
+		keys, _ := s.readBlocks(lower, upper)
+		return keys
+	}
+	if s.layout != COLUMNAR {
+		records := s.GetRange(lower, upper)
+		keys := make([]float64, len(records))
+		for i, r := range records {
+			keys[i] = r.Key()
+		}
+		return keys
+	}
+	b := make([]byte, (upper-lower+1)*KEY_LEN)
+	_, err := s.readAt(b, s.offset()+lower*KEY_LEN)
+	check(err)
+	keys := make([]float64, len(b)/KEY_LEN)
+	for i := range keys {
+		keys[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[i*KEY_LEN:]))
+	}
+	return keys
+}
+
+/*
+GetValues reads the values from position lower to upper (both included)
+*/
+func (s Store) GetValues(lower, upper int64) []uint64 {
+	if s.layout == COMPRESSED {
+		_, values := s.readBlocks(lower, upper)
+		return values
+	}
+	if s.layout != COLUMNAR {
+		records := s.GetRange(lower, upper)
+		values := make([]uint64, len(records))
+		for i, r := range records {
+			values[i] = r.Value()
+		}
+		return values
+	}
+	b := make([]byte, (upper-lower+1)*VALUE_LEN)
+	_, err := s.readAt(b, s.offset()+s.count*KEY_LEN+lower*VALUE_LEN)
+	check(err)
+	values := make([]uint64, len(b)/VALUE_LEN)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint64(b[i*VALUE_LEN:])
+	}
+	return values
+}
+
+// columns reads the keys and the values of a COLUMNAR or a COMPRESSED store
+func (s Store) columns(lower, upper int64) ([]float64, []uint64) {
+	if s.layout == COMPRESSED {
+		return s.readBlocks(lower, upper)
+	}
+	return s.GetKeys(lower, upper), s.GetValues(lower, upper)
+}
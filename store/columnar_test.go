@@ -0,0 +1,129 @@
+package store
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite_Interleaved(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+
+	// when
+	s, err := Write(f, []byte{9}, Options{}, []float64{1, 2}, []uint64{3, 4})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, INTERLEAVED, s.Layout())
+	assert.Equal(t, int64(2), s.RecordCount())
+	assert.Equal(t, []Record{ToRecord(1, 3), ToRecord(2, 4)}, s.GetRange(0, 1))
+}
+
+func TestWrite_Columnar(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+
+	// when
+	s, err := Write(f, []byte{9}, Options{Layout: COLUMNAR}, []float64{1, 2, 2.5}, []uint64{3, 4, 5})
+
+	// then
+	assert.NoError(t, err)
+	result := make([]byte, 48)
+	f.ReadAt(result, s.offset())
+	assert.Equal(t, []byte{
+		0, 0, 0, 0, 0, 0, 240, 63, // 1.0
+		0, 0, 0, 0, 0, 0, 0, 64, // 2.0
+		0, 0, 0, 0, 0, 0, 4, 64, // 2.5
+		3, 0, 0, 0, 0, 0, 0, 0,
+		4, 0, 0, 0, 0, 0, 0, 0,
+		5, 0, 0, 0, 0, 0, 0, 0,
+	}, result)
+	assert.Equal(t, int64(3), s.RecordCount())
+	meta, _ := s.Meta()
+	assert.Equal(t, []byte{9}, meta)
+}
+
+func TestOpen_Columnar(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	Write(f, nil, Options{Layout: COLUMNAR, PageSize: 64}, []float64{1, 2, 2.5}, []uint64{3, 4, 5})
+
+	// when
+	s, err := Open(f)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, COLUMNAR, s.Layout())
+	assert.Equal(t, []float64{2, 2.5}, s.GetKeys(1, 2))
+	assert.Equal(t, []uint64{3, 4}, s.GetValues(0, 1))
+	assert.Equal(t, ToRecord(2.5, 5), s.Get(2))
+	assert.Equal(t, []Record{ToRecord(1, 3), ToRecord(2, 4)}, s.GetRange(0, 1))
+	assert.Panics(t, func() { s.Put(ToRecord(3, 6)) })
+}
+
+func TestGetKeysAndValues_Interleaved(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	s, _ := Write(f, nil, Options{}, []float64{1, 2, 2.5}, []uint64{3, 4, 5})
+
+	// when
+	keys, values := s.GetKeys(1, 2), s.GetValues(1, 2)
+
+	// then
+	assert.Equal(t, []float64{2, 2.5}, keys)
+	assert.Equal(t, []uint64{4, 5}, values)
+}
+
+func TestWrite_Errors(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+
+	// when
+	_, err := Write(f, nil, Options{}, []float64{1, 2}, []uint64{3})
+	// then
+	assert.Error(t, err)
+
+	// when
+	_, err = Write(f, nil, Options{PageSize: 10}, []float64{1}, []uint64{3})
+	// then
+	assert.Error(t, err)
+}
+
+func TestMap_Columnar(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	Write(f, []byte("model"), Options{Layout: COLUMNAR}, []float64{1, 2, 2.5}, []uint64{3, 4, 5})
+
+	// when
+	m, err := Map(f.Name())
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, COLUMNAR, m.Layout)
+	assert.Nil(t, m.Entries)
+	assert.Equal(t, []float64{1, 2, 2.5}, m.Keys)
+	assert.Equal(t, []uint64{3, 4, 5}, m.Values)
+	m.Close()
+}
+
+func TestParseLayout(t *testing.T) {
+	l, err := ParseLayout("columnar")
+	assert.NoError(t, err)
+	assert.Equal(t, COLUMNAR, l)
+
+	l, err = ParseLayout("interleaved")
+	assert.NoError(t, err)
+	assert.Equal(t, INTERLEAVED, l)
+
+	_, err = ParseLayout("rows")
+	assert.Error(t, err)
+	assert.Equal(t, "Layout(7)", Layout(7).String())
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	BLOCK_LEN    = 128 // records per compressed block
+	BLOCK_HEADER = 24  // distinct keys + 3 bit widths + padding, base key, first value
+)
+
+/*
+encodeCompressed returns the data section of a COMPRESSED store: a directory holding
+the offset of each block followed by the end of the last one, then the blocks.
+Each block is decoded on its own, so that reading a window of records only reads its blocks
+*/
+func encodeCompressed(keys []float64, values []uint64) []byte {
+	nblocks := (len(keys) + BLOCK_LEN - 1) / BLOCK_LEN
+	dir := make([]byte, (nblocks+1)*8)
+	blocks := []byte{}
+	for b := 0; b < nblocks; b++ {
+		binary.LittleEndian.PutUint64(dir[b*8:], uint64(len(blocks)))
+		end := (b + 1) * BLOCK_LEN
+		if end > len(keys) {
+			end = len(keys)
+		}
+		blocks = append(blocks, encodeBlock(keys[b*BLOCK_LEN:end], values[b*BLOCK_LEN:end])...)
+	}
+	binary.LittleEndian.PutUint64(dir[nblocks*8:], uint64(len(blocks)))
+	return append(dir, blocks...)
+}
+
+/*
+encodeBlock writes the distinct keys of the block as a dictionary, in frame of reference
+of the smallest one, and each key as its position in the dictionary.
+The values are written as the first one followed by the zigzag deltas between consecutive values.
+All these integers are bit-packed on the width of the largest one
+*/
+func encodeBlock(keys []float64, values []uint64) []byte {
+	dict := []uint64{}
+	positions := make([]uint64, len(keys))
+	for i, k := range keys {
+		if s := sortable(k); len(dict) == 0 || dict[len(dict)-1] != s {
+			dict = append(dict, s)
+		}
+		positions[i] = uint64(len(dict) - 1)
+	}
+	base := dict[0]
+	for i := range dict {
+		dict[i] -= base
+	}
+	deltas := make([]uint64, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		d := int64(values[i] - values[i-1])
+		deltas[i-1] = uint64(d<<1) ^ uint64(d>>63)
+	}
+	keyWidth, posWidth, deltaWidth := width(dict), width(positions), width(deltas)
+
+	b := make([]byte, BLOCK_HEADER)
+	binary.LittleEndian.PutUint32(b, uint32(len(dict)))
+	b[4], b[5], b[6] = keyWidth, posWidth, deltaWidth
+	binary.LittleEndian.PutUint64(b[8:], base)
+	binary.LittleEndian.PutUint64(b[16:], values[0])
+	b = append(b, pack(dict, keyWidth)...)
+	b = append(b, pack(positions, posWidth)...)
+	return append(b, pack(deltas, deltaWidth)...)
+}
+
+/*
+decodeBlock reads the n keys and values of a block written by encodeBlock
+*/
+func decodeBlock(b []byte, n int) (keys []float64, values []uint64, err error) {
+	if len(b) < BLOCK_HEADER {
+		return nil, nil, fmt.Errorf("the compressed block is truncated")
+	}
+	ndict := int(binary.LittleEndian.Uint32(b))
+	keyWidth, posWidth, deltaWidth := b[4], b[5], b[6]
+	base, first := binary.LittleEndian.Uint64(b[8:]), binary.LittleEndian.Uint64(b[16:])
+	b = b[BLOCK_HEADER:]
+	if len(b) < packedLen(ndict, keyWidth)+packedLen(n, posWidth)+packedLen(n-1, deltaWidth) {
+		return nil, nil, fmt.Errorf("the compressed block is truncated")
+	}
+	dict := unpack(b, ndict, keyWidth)
+	b = b[packedLen(ndict, keyWidth):]
+	positions := unpack(b, n, posWidth)
+	b = b[packedLen(n, posWidth):]
+	deltas := unpack(b, n-1, deltaWidth)
+
+	keys, values = make([]float64, n), make([]uint64, n)
+	for i, p := range positions {
+		if p >= uint64(ndict) {
+			return nil, nil, fmt.Errorf("the key %d of the block is out of its dictionary", i)
+		}
+		keys[i] = unsortable(dict[p] + base)
+	}
+	values[0] = first
+	for i, d := range deltas {
+		values[i+1] = values[i] + uint64(int64(d>>1)^-int64(d&1))
+	}
+	return keys, values, nil
+}
+
+/*
+readBlocks decodes the blocks holding the records from position lower to upper (both included)
+with a single ReadAt
+*/
+func (s Store) readBlocks(lower, upper int64) (keys []float64, values []uint64) {
+	first, last := lower/BLOCK_LEN, upper/BLOCK_LEN
+	b := make([]byte, s.blocks[last+1]-s.blocks[first])
+	_, err := s.readAt(b, s.offset()+int64(len(s.blocks))*8+s.blocks[first])
+	check(err)
+	keys, values, err = decodeBlocks(b, s.blocks[first:last+2], first, s.count)
+	check(err)
+	from := lower - first*BLOCK_LEN
+	return keys[from : from+upper-lower+1], values[from : from+upper-lower+1]
+}
+
+// decodeBlocks decodes the consecutive blocks of b, starting at the block first, dir holding their offsets
+func decodeBlocks(b []byte, dir []int64, first, count int64) (keys []float64, values []uint64, err error) {
+	for i := 0; i < len(dir)-1; i++ {
+		n := count - (first+int64(i))*BLOCK_LEN
+		if n > BLOCK_LEN {
+			n = BLOCK_LEN
+		}
+		k, v, err := decodeBlock(b[dir[i]-dir[0]:dir[i+1]-dir[0]], int(n))
+		if err != nil {
+			return nil, nil, err
+		}
+		keys, values = append(keys, k...), append(values, v...)
+	}
+	return keys, values, nil
+}
+
+// readDirectory reads the offsets of the compressed blocks, relative to the end of the directory
+func readDirectory(r io.ReaderAt, dataOffset, count int64) ([]int64, error) {
+	nblocks := (count + BLOCK_LEN - 1) / BLOCK_LEN
+	b := make([]byte, (nblocks+1)*8)
+	if _, err := r.ReadAt(b, dataOffset); err != nil {
+		return nil, err
+	}
+	dir := make([]int64, nblocks+1)
+	for i := range dir {
+		dir[i] = int64(binary.LittleEndian.Uint64(b[i*8:]))
+	}
+	if !sort.SliceIsSorted(dir, func(i, j int) bool { return dir[i] < dir[j] }) {
+		return nil, fmt.Errorf("the directory of the compressed blocks is corrupted")
+	}
+	return dir, nil
+}
+
+// sortable maps a float64 to an uint64 keeping the order, negative numbers included
+func sortable(f float64) uint64 {
+	u := math.Float64bits(f)
+	if u>>63 == 1 {
+		return ^u
+	}
+	return u | 1<<63
+}
+
+func unsortable(u uint64) float64 {
+	if u>>63 == 1 {
+		return math.Float64frombits(u &^ (1 << 63))
+	}
+	return math.Float64frombits(^u)
+}
+
+// width returns the number of bits needed by the largest value
+func width(values []uint64) uint8 {
+	max := uint64(0)
+	for _, v := range values {
+		max |= v
+	}
+	return uint8(bits.Len64(max))
+}
+
+func packedLen(n int, width uint8) int {
+	if n <= 0 {
+		return 0
+	}
+	return (n*int(width) + 7) / 8
+}
+
+// pack writes the low width bits of each value one after the other
+func pack(values []uint64, width uint8) []byte {
+	b := make([]byte, packedLen(len(values), width))
+	pos := 0
+	for _, v := range values {
+		for bit := 0; bit < int(width); bit++ {
+			if v>>uint(bit)&1 == 1 {
+				b[pos/8] |= 1 << uint(pos%8)
+			}
+			pos++
+		}
+	}
+	return b
+}
+
+func unpack(b []byte, n int, width uint8) []uint64 {
+	if n <= 0 {
+		return nil
+	}
+	values := make([]uint64, n)
+	pos := 0
+	for i := range values {
+		for bit := 0; bit < int(width); bit++ {
+			if b[pos/8]>>uint(pos%8)&1 == 1 {
+				values[i] |= 1 << uint(bit)
+			}
+			pos++
+		}
+	}
+	return values
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"io/ioutil"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortable(t *testing.T) {
+	// given
+	keys := []float64{math.Inf(-1), -300.5, -2, -0.1, 0, 0.1, 2, 3, 3.14, 1e300, math.Inf(1)}
+
+	for i := 1; i < len(keys); i++ {
+		// then the order is kept
+		assert.Less(t, sortable(keys[i-1]), sortable(keys[i]))
+		// and the key is decoded back
+		assert.Equal(t, keys[i], unsortable(sortable(keys[i])))
+	}
+}
+
+func TestPack(t *testing.T) {
+	// given
+	values := []uint64{0, 5, 7, 1, 6}
+
+	// when
+	b := pack(values, 3)
+
+	// then
+	assert.Len(t, b, 2)
+	assert.Equal(t, values, unpack(b, 5, 3))
+	assert.Equal(t, uint8(3), width(values))
+	assert.Equal(t, uint8(0), width([]uint64{0, 0}))
+	assert.Equal(t, []uint64{0, 0}, unpack(pack([]uint64{0, 0}, 0), 2, 0))
+}
+
+func TestEncodeDecodeBlock(t *testing.T) {
+	// given duplicate keys and unsorted values
+	keys := []float64{-1.5, 2.5, 2.98, 3, 3, 3, 3.14, 5, 10}
+	values := []uint64{8, 5, 6, 1, 2, 3, 7, 0, 4}
+
+	// when
+	b := encodeBlock(keys, values)
+	decodedKeys, decodedValues, err := decodeBlock(b, len(keys))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, keys, decodedKeys)
+	assert.Equal(t, values, decodedValues)
+	assert.Less(t, len(b), len(keys)*int(RECORD_LEN))
+
+	// when the block is truncated
+	_, _, err = decodeBlock(b[:len(b)-1], len(keys))
+	// then
+	assert.Error(t, err)
+	_, _, err = decodeBlock(b[:10], len(keys))
+	assert.Error(t, err)
+}
+
+func TestWrite_Compressed(t *testing.T) {
+	// given 3 blocks of keys with many duplicates
+	keys, values := make([]float64, 2*BLOCK_LEN+10), make([]uint64, 2*BLOCK_LEN+10)
+	for i := range keys {
+		keys[i], values[i] = float64(i/7), uint64(len(keys)-i)
+	}
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+
+	// when
+	s, err := Write(f, []byte("model"), Options{Layout: COMPRESSED}, keys, values)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, COMPRESSED, s.Layout())
+	assert.Equal(t, int64(len(keys)), s.RecordCount())
+	stat, _ := f.Stat()
+	assert.Less(t, stat.Size(), int64(len(keys))*RECORD_LEN/4)
+
+	// when reading a window overlapping 2 blocks
+	opened, err := Open(f)
+	records := opened.GetRange(BLOCK_LEN-2, BLOCK_LEN+1)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []Record{
+		ToRecord(keys[BLOCK_LEN-2], values[BLOCK_LEN-2]),
+		ToRecord(keys[BLOCK_LEN-1], values[BLOCK_LEN-1]),
+		ToRecord(keys[BLOCK_LEN], values[BLOCK_LEN]),
+		ToRecord(keys[BLOCK_LEN+1], values[BLOCK_LEN+1]),
+	}, records)
+	assert.Equal(t, keys[len(keys)-3:], opened.GetKeys(int64(len(keys)-3), int64(len(keys)-1)))
+	assert.Equal(t, values[:2], opened.GetValues(0, 1))
+	assert.Equal(t, ToRecord(keys[200], values[200]), opened.Get(200))
+	assert.Panics(t, func() { opened.Put(ToRecord(1, 1)) })
+}
+
+func TestMap_Compressed(t *testing.T) {
+	// given
+	keys := []float64{-3, 1, 1, 2, 8}
+	sort.Float64s(keys)
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	Write(f, nil, Options{Layout: COMPRESSED, PageSize: PAGE_SIZE}, keys, []uint64{4, 3, 2, 1, 0})
+
+	// when
+	m, err := Map(f.Name())
+	k, v, errRange := m.Range(1, 3)
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, errRange)
+	assert.Equal(t, 5, m.Count())
+	assert.Equal(t, []float64{1, 1, 2}, k)
+	assert.Equal(t, []uint64{3, 2, 1}, v)
+	m.Close()
+}
@@ -2,9 +2,9 @@ package store
 
 import (
 	"encoding/binary"
-	"log"
+	"fmt"
+	"io"
 	"math"
-	"os"
 )
 
 const (
@@ -12,6 +12,12 @@ const (
 	VALUE_LEN  = 8         //  uint64
 	RECORD_LEN = int64(16) // KEY_LEN + VALUE_LEN
 	HEADER     = 8
+
+	META_HEADER  = 16              // data offset + meta length, written after the count when META_FLAG is set
+	COUNT_MASK   = 1<<56 - 1       // the lower 56 bits of the header hold the record count
+	META_FLAG    = uint64(1 << 56) // the file carries a meta section between the header and the records
+	LAYOUT_SHIFT = 57              // the 2 bits following META_FLAG tell the Layout of the records
+	LAYOUT_MASK  = uint64(3) << LAYOUT_SHIFT
 )
 
 // Record is a key/value paire
@@ -38,31 +44,169 @@ func FromRecord(r Record) (key float64, value uint64) {
 	return key, value
 }
 
-// Store is a os.File where we store key value paires
+// Store is a Backend where we store key value paires
 type Store struct {
-	*os.File
+	Backend
+	dataOffset int64
+	pool       *BufferPool
+	layout     Layout
+	count      int64   // only kept for the COLUMNAR and COMPRESSED layouts, where it locates the sections
+	blocks     []int64 // directory of the COMPRESSED layout
+}
+
+/*
+Create writes a new header to f holding the meta bytes, the records will be appended after them.
+The meta section is opaque to the store, the index package uses it to persist its model
+*/
+func Create(f Backend, meta []byte) (Store, error) {
+	return CreatePaged(f, meta, 0)
+}
+
+/*
+CreatePaged works like Create but pads the header so that the records start on a page boundary.
+As pageSize is a multiple of RECORD_LEN, a record never overlaps two pages of a BufferPool
+*/
+func CreatePaged(f Backend, meta []byte, pageSize int64) (Store, error) {
+	return create(f, meta, pageSize, META_FLAG)
+}
+
+func create(f Backend, meta []byte, pageSize int64, word uint64) (Store, error) {
+	if pageSize%RECORD_LEN != 0 {
+		return Store{}, fmt.Errorf("the page size %d is not a multiple of the record length %d", pageSize, RECORD_LEN)
+	}
+	// the records are at least aligned on 8 bytes to be viewed in memory by Map
+	align := pageSize
+	if align == 0 {
+		align = KEY_LEN
+	}
+	dataOffset := HEADER + META_HEADER + int64(len(meta))
+	if dataOffset%align != 0 {
+		dataOffset += align - dataOffset%align
+	}
+	b := make([]byte, dataOffset)
+	binary.LittleEndian.PutUint64(b, word|META_FLAG)
+	binary.LittleEndian.PutUint64(b[HEADER:], uint64(dataOffset))
+	binary.LittleEndian.PutUint64(b[HEADER+8:], uint64(len(meta)))
+	copy(b[HEADER+META_HEADER:], meta)
+	if t, ok := f.(truncater); ok {
+		if err := t.Truncate(0); err != nil {
+			return Store{}, err
+		}
+	}
+	if _, err := f.WriteAt(b, 0); err != nil {
+		return Store{}, err
+	}
+	return Store{Backend: f, dataOffset: dataOffset}, nil
+}
+
+/*
+Open reads the header of f to know where the records start and how they are laid out
+*/
+func Open(f Backend) (Store, error) {
+	h, err := readHeader(f)
+	s := Store{Backend: f, dataOffset: h.dataOffset, layout: h.layout()}
+	if err != nil || s.layout == INTERLEAVED {
+		return s, err
+	}
+	s.count = h.count
+	if s.layout == COMPRESSED {
+		s.blocks, err = readDirectory(f, s.offset(), s.count)
+	}
+	return s, err
+}
+
+/*
+WithPool returns a copy of the store reading its records through the pages cached by p
+*/
+func (s Store) WithPool(p *BufferPool) Store {
+	s.pool = p
+	return s
+}
+
+/*
+Meta returns the bytes written by Create, or nil if the store has no meta section
+*/
+func (s Store) Meta() ([]byte, error) {
+	h, err := readHeader(s)
+	if err != nil || h.flags&META_FLAG == 0 {
+		return nil, err
+	}
+	meta := make([]byte, h.metaLen)
+	_, err = s.ReadAt(meta, HEADER+META_HEADER)
+	return meta, err
+}
+
+/*
+SetMeta rewrites the meta section in place, the new bytes must have the length of the ones written by Create
+*/
+func (s Store) SetMeta(meta []byte) error {
+	h, err := readHeader(s)
+	if err != nil {
+		return err
+	}
+	if h.flags&META_FLAG == 0 || h.metaLen != int64(len(meta)) {
+		return fmt.Errorf("the meta section of %d bytes can't be rewritten with %d bytes", h.metaLen, len(meta))
+	}
+	if _, err := s.WriteAt(meta, HEADER+META_HEADER); err != nil {
+		return err
+	}
+	if s.pool != nil {
+		s.pool.Invalidate(HEADER+META_HEADER, h.metaLen)
+	}
+	return nil
 }
 
 /*
 Get reads the store file at offset i and return a Record byte array
 */
 func (s Store) Get(i int64) Record {
-	offset := i*RECORD_LEN + HEADER
+	if s.layout != INTERLEAVED {
+		return ToRecord(s.GetKeys(i, i)[0], s.GetValues(i, i)[0])
+	}
+	offset := i*RECORD_LEN + s.offset()
 	b := make([]byte, RECORD_LEN)
-	_, err := s.ReadAt(b, offset)
+	_, err := s.readAt(b, offset)
 	check(err)
 	return Record(b)
 }
 
 /*
-Put appends a Record to the store file
+GetRange reads the records from position lower to upper (both included) with a single ReadAt
+*/
+func (s Store) GetRange(lower, upper int64) []Record {
+	if s.layout != INTERLEAVED {
+		keys, values := s.columns(lower, upper)
+		records := make([]Record, len(keys))
+		for i := range records {
+			records[i] = ToRecord(keys[i], values[i])
+		}
+		return records
+	}
+	n := upper - lower + 1
+	b := make([]byte, n*RECORD_LEN)
+	_, err := s.readAt(b, lower*RECORD_LEN+s.offset())
+	check(err)
+	records := make([]Record, n)
+	for i := range records {
+		records[i] = Record(b[int64(i)*RECORD_LEN : int64(i+1)*RECORD_LEN])
+	}
+	return records
+}
+
+/*
+Put appends a Record to the store file. The sections of COLUMNAR and COMPRESSED stores are written once by Write
 */
 func (s Store) Put(r Record) {
+	if s.layout != INTERLEAVED {
+		check(fmt.Errorf("can't append a record to a %s store", s.layout))
+	}
 	count := s.RecordCount()
-	offset := count*RECORD_LEN + HEADER
-	log.Println(count, RECORD_LEN, HEADER, offset)
+	offset := count*RECORD_LEN + s.offset()
 	_, err := s.WriteAt(r, offset)
 	check(err)
+	if s.pool != nil {
+		s.pool.Invalidate(offset, RECORD_LEN)
+	}
 	s.setRecordCount(count + 1)
 }
 
@@ -75,17 +219,64 @@ func (s Store) RecordCount() int64 {
 	if err != nil {
 		return int64(0)
 	}
-	return int64(binary.LittleEndian.Uint64(b))
+	return int64(binary.LittleEndian.Uint64(b) & COUNT_MASK)
 }
 
 func (s Store) setRecordCount(n int64) {
 	b := make([]byte, HEADER)
-	binary.LittleEndian.PutUint64(b, uint64(n))
+	// keep the flags already written in the header, a new file has none
+	s.ReadAt(b, 0)
+	flags := binary.LittleEndian.Uint64(b) &^ COUNT_MASK
+	binary.LittleEndian.PutUint64(b, uint64(n)|flags)
 
 	_, err := s.WriteAt(b, 0)
 	check(err)
 }
 
+func (s Store) readAt(b []byte, off int64) (int, error) {
+	if s.pool != nil {
+		return s.pool.ReadAt(b, off)
+	}
+	return s.ReadAt(b, off)
+}
+
+// header is the decoded beginning of a store file, dataOffset and metaLen are 0 without META_FLAG
+type header struct {
+	count               int64
+	flags               uint64
+	dataOffset, metaLen int64
+}
+
+func readHeader(r io.ReaderAt) (h header, err error) {
+	b := make([]byte, HEADER+META_HEADER)
+	if _, err = r.ReadAt(b[:HEADER], 0); err != nil {
+		return h, err
+	}
+	word := binary.LittleEndian.Uint64(b)
+	h.count, h.flags = int64(word&COUNT_MASK), word&^COUNT_MASK
+	if h.flags&META_FLAG == 0 {
+		return h, nil
+	}
+	if _, err = r.ReadAt(b[HEADER:], HEADER); err != nil {
+		return h, err
+	}
+	h.dataOffset = int64(binary.LittleEndian.Uint64(b[HEADER:]))
+	h.metaLen = int64(binary.LittleEndian.Uint64(b[HEADER+8:]))
+	return h, nil
+}
+
+func (h header) layout() Layout {
+	return Layout(h.flags & LAYOUT_MASK >> LAYOUT_SHIFT)
+}
+
+// offset returns where the first record is written, right after the header for files without meta
+func (s Store) offset() int64 {
+	if s.dataOffset > 0 {
+		return s.dataOffset
+	}
+	return HEADER
+}
+
 func check(e error) {
 	if e != nil {
 		panic(e)
@@ -55,7 +55,8 @@ func TestValue(t *testing.T) {
 func TestGet(t *testing.T) {
 	// given
 	tmpDir := t.TempDir()
-	f, _ := ioutil.TempFile(tmpDir, "*")
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
 	data := []byte{
 		5, 0, 0, 0, 0, 0, 0, 0, // RecordCount = 5
 		66, 96, 229, 208, 34, 199, 103, 64, 1, 0, 0, 0, 0, 0, 0, 0, // Record 0 = record(190.223, 0)
@@ -66,7 +67,7 @@ func TestGet(t *testing.T) {
 	}
 	f.WriteAt(data, 0)
 
-	store := Store{f}
+	store := Store{Backend: f}
 
 	// when
 	r := store.Get(4)
@@ -79,8 +80,9 @@ func TestGet(t *testing.T) {
 func TestPut(t *testing.T) {
 	// given
 	tmpDir := t.TempDir()
-	f, _ := ioutil.TempFile(tmpDir, "*")
-	store := Store{f}
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
+	store := Store{Backend: f}
 	r := Record([]byte{1, 0, 0, 8, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0})  // record(1.0, 1)
 	r2 := Record([]byte{2, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}) // record(2.0, 2)
 
@@ -103,10 +105,11 @@ func TestPut(t *testing.T) {
 func TestRecordCount(t *testing.T) {
 	// given
 	tmpDir := t.TempDir()
-	f, _ := ioutil.TempFile(tmpDir, "*")
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
 	data := []byte{200, 0, 0, 0, 0, 0, 0, 0}
 	f.WriteAt(data, 0)
-	store := Store{f}
+	store := Store{Backend: f}
 
 	// when
 	c := store.RecordCount()
@@ -118,8 +121,9 @@ func TestRecordCount(t *testing.T) {
 func TestRecordCount_NotExits(t *testing.T) {
 	// given
 	tmpDir := t.TempDir()
-	f, _ := ioutil.TempFile(tmpDir, "*")
-	store := Store{f}
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
+	store := Store{Backend: f}
 
 	// when
 	c := store.RecordCount()
@@ -131,8 +135,9 @@ func TestRecordCount_NotExits(t *testing.T) {
 func TestSetRecordCount(t *testing.T) {
 	// given
 	tmpDir := t.TempDir()
-	f, _ := ioutil.TempFile(tmpDir, "*")
-	store := Store{f}
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
+	store := Store{Backend: f}
 
 	// when
 	store.setRecordCount(15)
@@ -144,11 +149,121 @@ func TestSetRecordCount(t *testing.T) {
 	assert.Equal(t, uint64(15), binary.LittleEndian.Uint64(count))
 }
 
+func TestGetRange(t *testing.T) {
+	// given
+	tmpDir := t.TempDir()
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
+	store := Store{Backend: f}
+	for i := 0; i < 5; i++ {
+		store.Put(ToRecord(float64(i)*1.5, uint64(i)))
+	}
+
+	// when
+	records := store.GetRange(1, 3)
+
+	// then
+	assert.Len(t, records, 3)
+	assert.Equal(t, 1.5, records[0].Key())
+	assert.Equal(t, 3., records[1].Key())
+	assert.Equal(t, 4.5, records[2].Key())
+	assert.Equal(t, 3, int(records[2].Value()))
+}
+
+func TestCreate(t *testing.T) {
+	// given
+	tmpDir := t.TempDir()
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
+
+	// when
+	store, err := Create(f, []byte{1, 2, 3})
+	store.Put(ToRecord(2, 7))
+
+	// then
+	assert.NoError(t, err)
+	result := make([]byte, 48)
+	f.ReadAt(result, 0)
+	assert.Equal(t, []byte{
+		1, 0, 0, 0, 0, 0, 0, 1, // RecordCount = 1 | META_FLAG
+		32, 0, 0, 0, 0, 0, 0, 0, // data offset
+		3, 0, 0, 0, 0, 0, 0, 0, // meta length
+		1, 2, 3, // meta
+		0, 0, 0, 0, 0, // padding to align the records on 8 bytes
+		0, 0, 0, 0, 0, 0, 0, 64, 7, 0, 0, 0, 0, 0, 0, 0, // record(2.0, 7)
+	}, result)
+	assert.Equal(t, int64(1), store.RecordCount())
+}
+
+func TestOpen(t *testing.T) {
+	// given
+	tmpDir := t.TempDir()
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
+	s, _ := Create(f, []byte("model"))
+	s.Put(ToRecord(1, 1))
+	s.Put(ToRecord(2, 2))
+
+	// when
+	store, err := Open(f)
+	meta, errMeta := store.Meta()
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, errMeta)
+	assert.Equal(t, []byte("model"), meta)
+	assert.Equal(t, int64(2), store.RecordCount())
+	assert.Equal(t, 2., store.Get(1).Key())
+}
+
+func TestSetMeta(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	s, _ := Create(f, []byte("model"))
+	s.Put(ToRecord(1, 1))
+
+	// when
+	err := s.SetMeta([]byte("MODEL"))
+	meta, _ := s.Meta()
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("MODEL"), meta)
+	assert.Equal(t, 1., s.Get(0).Key())
+
+	// when the length differs
+	err = s.SetMeta([]byte("a longer model"))
+	meta, _ = s.Meta()
+
+	// then
+	assert.Error(t, err)
+	assert.Equal(t, []byte("MODEL"), meta)
+}
+
+func TestOpen_WithoutMeta(t *testing.T) {
+	// given
+	tmpDir := t.TempDir()
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
+	Store{Backend: f}.Put(ToRecord(190.223, 4))
+
+	// when
+	store, err := Open(f)
+	meta, _ := store.Meta()
+
+	// then
+	assert.NoError(t, err)
+	assert.Nil(t, meta)
+	assert.Equal(t, 190.223, store.Get(0).Key())
+}
+
 func ExampleStore() {
 
 	tmpDir := os.TempDir()
-	f, _ := ioutil.TempFile(tmpDir, "*")
-	store := Store{f}
+	file, _ := ioutil.TempFile(tmpDir, "*")
+	f := NewFileBackend(file)
+	store := Store{Backend: f}
 
 	store.Put(ToRecord(1.99, 0))
 	store.Put(ToRecord(2.08, 1))
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"reflect"
+	"unsafe"
+)
+
+/*
+Entry has the memory layout of a Record, a mapped file is viewed as a slice of entries
+*/
+type Entry struct {
+	Key   float64
+	Value uint64
+}
+
+/*
+Mapped is a store file mapped in memory. The meta section and the records
+are views over the mapping: nothing is copied, and the pages are shared with
+the other processes mapping the same file.
+An INTERLEAVED store is viewed as Entries, a COLUMNAR one as Keys and Values.
+The blocks of a COMPRESSED store are decoded from the mapping by Range
+*/
+type Mapped struct {
+	data    []byte
+	Layout  Layout
+	Meta    []byte
+	Entries []Entry
+	Keys    []float64
+	Values  []uint64
+
+	start, count int64
+	blocks       []int64
+}
+
+/*
+Map maps the whole file at path in memory, read only
+*/
+func Map(path string) (*Mapped, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// the mapping stays valid once the file is closed
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() < HEADER {
+		return nil, fmt.Errorf("%s is too small to be a store file", path)
+	}
+	data, err := mmap(f, int(stat.Size()))
+	if err != nil {
+		return nil, err
+	}
+	m, err := view(data)
+	if err != nil {
+		munmap(data)
+		return nil, err
+	}
+	return m, nil
+}
+
+func view(data []byte) (*Mapped, error) {
+	h, err := readHeader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	m := &Mapped{data: data}
+	start := int64(HEADER)
+	if h.flags&META_FLAG != 0 {
+		end := HEADER + META_HEADER + h.metaLen
+		if h.metaLen < 0 || h.dataOffset < end || h.dataOffset > int64(len(data)) {
+			return nil, fmt.Errorf("the file is truncated: a meta section of %d bytes and records at %d expected", h.metaLen, h.dataOffset)
+		}
+		m.Meta = data[HEADER+META_HEADER : end]
+		start = h.dataOffset
+	}
+	m.Layout, m.start, m.count = h.layout(), start, h.count
+	if m.Layout == COMPRESSED {
+		m.blocks, err = readDirectory(bytes.NewReader(data), start, h.count)
+		if err == nil && start+int64(len(m.blocks))*8+m.blocks[len(m.blocks)-1] > int64(len(data)) {
+			err = fmt.Errorf("the file is truncated: %d compressed blocks expected", len(m.blocks)-1)
+		}
+		return m, err
+	}
+	if start+h.count*RECORD_LEN > int64(len(data)) {
+		return nil, fmt.Errorf("the file is truncated: %d records expected", h.count)
+	}
+	if h.count == 0 {
+		return m, nil
+	}
+	if !nativeLittleEndian() {
+		return nil, fmt.Errorf("the records are little endian and can't be viewed on this platform")
+	}
+	if start%KEY_LEN != 0 {
+		return nil, fmt.Errorf("the records can't be viewed in memory, they are not aligned on %d bytes", KEY_LEN)
+	}
+	if m.Layout == COLUMNAR {
+		sliceAt(unsafe.Pointer(&m.Keys), data, start, h.count)
+		sliceAt(unsafe.Pointer(&m.Values), data, start+h.count*KEY_LEN, h.count)
+		return m, nil
+	}
+	sliceAt(unsafe.Pointer(&m.Entries), data, start, h.count)
+	return m, nil
+}
+
+// sliceAt points the slice header at ptr to the n elements starting at data[start]
+func sliceAt(ptr unsafe.Pointer, data []byte, start, n int64) {
+	sh := (*reflect.SliceHeader)(ptr)
+	sh.Data = uintptr(unsafe.Pointer(&data[start]))
+	sh.Len, sh.Cap = int(n), int(n)
+}
+
+/*
+Count returns the number of records of the store
+*/
+func (m *Mapped) Count() int {
+	return int(m.count)
+}
+
+/*
+Range decodes the keys and the values from position lower to upper (both included) of a COMPRESSED store,
+only the blocks holding them are read from the mapping
+*/
+func (m *Mapped) Range(lower, upper int64) (keys []float64, values []uint64, err error) {
+	if m.Layout != COMPRESSED {
+		return nil, nil, fmt.Errorf("the records of a %s store are viewed without decoding", m.Layout)
+	}
+	first, last := lower/BLOCK_LEN, upper/BLOCK_LEN
+	blocks := m.data[m.start+int64(len(m.blocks))*8:]
+	keys, values, err = decodeBlocks(blocks[m.blocks[first]:m.blocks[last+1]], m.blocks[first:last+2], first, m.count)
+	if err != nil {
+		return nil, nil, err
+	}
+	from := lower - first*BLOCK_LEN
+	return keys[from : from+upper-lower+1], values[from : from+upper-lower+1], nil
+}
+
+/*
+ReadAt copies the bytes of the mapping at off, to read the sections appended after the records
+*/
+func (m *Mapped) ReadAt(b []byte, off int64) (int, error) {
+	return bytes.NewReader(m.data).ReadAt(b, off)
+}
+
+/*
+Close unmaps the file, the views must not be used anymore
+*/
+func (m *Mapped) Close() error {
+	m.Meta, m.Entries, m.Keys, m.Values = nil, nil, nil, nil
+	return munmap(m.data)
+}
+
+func nativeLittleEndian() bool {
+	b := [2]byte{}
+	*(*uint16)(unsafe.Pointer(&b[0])) = 1
+	return binary.LittleEndian.Uint16(b[:]) == 1
+}
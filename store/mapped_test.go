@@ -0,0 +1,91 @@
+package store
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	s, _ := Create(f, []byte("modelxxx"))
+	s.Put(ToRecord(1.99, 0))
+	s.Put(ToRecord(2.08, 1))
+	s.Put(ToRecord(2.33, 3))
+
+	// when
+	m, err := Map(f.Name())
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("modelxxx"), m.Meta)
+	assert.Equal(t, []Entry{{1.99, 0}, {2.08, 1}, {2.33, 3}}, m.Entries)
+	assert.NoError(t, m.Close())
+}
+
+func TestMap_WithoutMeta(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	s := Store{Backend: f}
+	s.Put(ToRecord(190.223, 4))
+
+	// when
+	m, err := Map(f.Name())
+
+	// then
+	assert.NoError(t, err)
+	assert.Nil(t, m.Meta)
+	assert.Equal(t, []Entry{{190.223, 4}}, m.Entries)
+	m.Close()
+}
+
+func TestMap_Errors(t *testing.T) {
+	// given a file too small
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	f.Write([]byte{1, 0})
+	// when
+	_, err := Map(f.Name())
+	// then
+	assert.Error(t, err)
+
+	// given a truncated file
+	f.WriteAt([]byte{2, 0, 0, 0, 0, 0, 0, 0}, 0)
+	// when
+	_, err = Map(f.Name())
+	// then
+	assert.Error(t, err)
+
+	// given records not aligned on 8 bytes
+	Create(f, []byte("abc"))
+	f.WriteAt([]byte{27}, HEADER)
+	Store{Backend: f, dataOffset: 27}.Put(ToRecord(1, 1))
+	// when
+	_, err = Map(f.Name())
+	// then
+	assert.Error(t, err)
+
+	// given a meta section longer than the file
+	Create(f, []byte("modelxxx"))
+	f.WriteAt([]byte{0, 0, 16, 0, 0, 0, 0, 0}, HEADER+8)
+	// when
+	_, err = Map(f.Name())
+	// then
+	assert.EqualError(t, err, "the file is truncated: a meta section of 1048576 bytes and records at 32 expected")
+
+	// given records starting after the end of the file
+	Create(f, []byte("modelxxx"))
+	f.WriteAt([]byte{0, 0, 16, 0, 0, 0, 0, 0}, HEADER)
+	// when
+	_, err = Map(f.Name())
+	// then
+	assert.Error(t, err)
+
+	// given a missing file
+	_, err = Map(f.Name() + ".missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package store
+
+import (
+	"io"
+	"os"
+)
+
+// mmap falls back to a copy of the file where the syscall is not available
+func mmap(f *os.File, size int) ([]byte, error) {
+	b := make([]byte, size)
+	_, err := io.ReadFull(io.NewSectionReader(f, 0, int64(size)), b)
+	return b, err
+}
+
+func munmap(b []byte) error {
+	return nil
+}
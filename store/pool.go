@@ -0,0 +1,121 @@
+package store
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+const PAGE_SIZE = int64(4096) // 256 records
+
+/*
+PoolStats counts the page requests served by a BufferPool since its creation
+*/
+type PoolStats struct {
+	Hits, Misses int64
+	Resident     int // number of pages currently cached
+}
+
+/*
+BufferPool is an io.ReaderAt caching the fixed-size pages of a file.
+When the pool is full, the least recently used page is evicted
+*/
+type BufferPool struct {
+	src      io.ReaderAt
+	pageSize int64
+	capacity int
+
+	mu    sync.Mutex
+	lru   *list.List // front is the most recently used page
+	pages map[int64]*list.Element
+	stats PoolStats
+}
+
+type page struct {
+	id   int64
+	data []byte // shorter than pageSize for the last page of the file
+}
+
+/*
+NewBufferPool returns a pool keeping at most capacity pages of pageSize bytes read from src
+*/
+func NewBufferPool(src io.ReaderAt, pageSize int64, capacity int) *BufferPool {
+	return &BufferPool{
+		src:      src,
+		pageSize: pageSize,
+		capacity: capacity,
+		lru:      list.New(),
+		pages:    map[int64]*list.Element{},
+	}
+}
+
+/*
+ReadAt copies into b the bytes starting at off, fetching from src only the pages not cached yet
+*/
+func (p *BufferPool) ReadAt(b []byte, off int64) (n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for n < len(b) {
+		pos := off + int64(n)
+		pg, err := p.page(pos / p.pageSize)
+		if err != nil {
+			return n, err
+		}
+		start := pos % p.pageSize
+		if start >= int64(len(pg.data)) {
+			return n, io.EOF
+		}
+		n += copy(b[n:], pg.data[start:])
+	}
+	return n, nil
+}
+
+/*
+Invalidate drops the cached pages overlapping the length bytes starting at off
+*/
+func (p *BufferPool) Invalidate(off, length int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id := off / p.pageSize; id <= (off+length-1)/p.pageSize; id++ {
+		if e, ok := p.pages[id]; ok {
+			p.lru.Remove(e)
+			delete(p.pages, id)
+		}
+	}
+}
+
+/*
+Stats returns the hit and miss counters of the pool
+*/
+func (p *BufferPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.stats
+	s.Resident = p.lru.Len()
+	return s
+}
+
+func (p *BufferPool) page(id int64) (*page, error) {
+	if e, ok := p.pages[id]; ok {
+		p.stats.Hits++
+		p.lru.MoveToFront(e)
+		return e.Value.(*page), nil
+	}
+	p.stats.Misses++
+	data := make([]byte, p.pageSize)
+	n, err := p.src.ReadAt(data, id*p.pageSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	pg := &page{id: id, data: data[:n]}
+	if p.capacity <= 0 {
+		return pg, nil
+	}
+	if p.lru.Len() >= p.capacity {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.pages, oldest.Value.(*page).id)
+	}
+	p.pages[id] = p.lru.PushFront(pg)
+	return pg, nil
+}
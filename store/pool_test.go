@@ -0,0 +1,124 @@
+package store
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferPoolReadAt(t *testing.T) {
+	// given 3 pages of 4 bytes, the last one is short
+	src := bytes.NewReader([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	pool := NewBufferPool(src, 4, 2)
+	b := make([]byte, 5)
+
+	// when the read overlaps the 2 first pages
+	n, err := pool.ReadAt(b, 2)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, []byte{2, 3, 4, 5, 6}, b)
+	assert.Equal(t, PoolStats{Hits: 0, Misses: 2, Resident: 2}, pool.Stats())
+
+	// when the pages are already cached
+	n, err = pool.ReadAt(b[:2], 4)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{4, 5}, b[:2])
+	assert.Equal(t, PoolStats{Hits: 1, Misses: 2, Resident: 2}, pool.Stats())
+
+	// when reading past the end of the file
+	n, err = pool.ReadAt(b, 8)
+	// then
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte{8, 9}, b[:2])
+}
+
+func TestBufferPoolEviction(t *testing.T) {
+	// given a pool of 2 pages
+	src := bytes.NewReader([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11})
+	pool := NewBufferPool(src, 4, 2)
+	b := make([]byte, 1)
+
+	// when page 0 is the least recently used
+	pool.ReadAt(b, 0)
+	pool.ReadAt(b, 4)
+	pool.ReadAt(b, 5)
+	pool.ReadAt(b, 8)
+	// then page 0 is read again
+	pool.ReadAt(b, 0)
+	assert.Equal(t, PoolStats{Hits: 1, Misses: 4, Resident: 2}, pool.Stats())
+	// and page 2 is still cached
+	pool.ReadAt(b, 9)
+	assert.Equal(t, PoolStats{Hits: 2, Misses: 4, Resident: 2}, pool.Stats())
+}
+
+func TestBufferPoolInvalidate(t *testing.T) {
+	// given
+	src := bytes.NewReader([]byte{0, 1, 2, 3, 4, 5, 6, 7})
+	pool := NewBufferPool(src, 4, 2)
+	b := make([]byte, 8)
+	pool.ReadAt(b, 0)
+
+	// when
+	pool.Invalidate(5, 1)
+
+	// then
+	assert.Equal(t, 1, pool.Stats().Resident)
+	pool.ReadAt(b, 0)
+	assert.Equal(t, PoolStats{Hits: 1, Misses: 3, Resident: 2}, pool.Stats())
+}
+
+func TestCreatePaged(t *testing.T) {
+	// given
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+
+	// when
+	s, err := CreatePaged(f, []byte("model"), 64)
+	s.Put(ToRecord(2, 7))
+	s.Put(ToRecord(3, 8))
+
+	// then
+	assert.NoError(t, err)
+	stat, _ := f.Stat()
+	assert.Equal(t, int64(64+2*RECORD_LEN), stat.Size())
+	opened, _ := Open(f)
+	assert.Equal(t, 3., opened.Get(1).Key())
+	meta, _ := opened.Meta()
+	assert.Equal(t, []byte("model"), meta)
+
+	// when the page size can't hold whole records
+	_, err = CreatePaged(f, nil, 100)
+	// then
+	assert.Error(t, err)
+}
+
+func TestStoreWithPool(t *testing.T) {
+	// given 8 records per page
+	file, _ := ioutil.TempFile(t.TempDir(), "*")
+	f := NewFileBackend(file)
+	s, _ := CreatePaged(f, nil, 128)
+	for i := 0; i < 20; i++ {
+		s.Put(ToRecord(float64(i), uint64(i)))
+	}
+	pool := NewBufferPool(f, 128, 4)
+	s = s.WithPool(pool)
+
+	// when the range overlaps the records pages 1 and 2
+	records := s.GetRange(6, 9)
+
+	// then
+	assert.Len(t, records, 4)
+	assert.Equal(t, 6., records[0].Key())
+	assert.Equal(t, 9., records[3].Key())
+	assert.Equal(t, int64(2), pool.Stats().Misses)
+
+	// when the record is written through the store, its page is invalidated
+	s.Put(ToRecord(20, 20))
+	assert.Equal(t, 20., s.Get(20).Key())
+}
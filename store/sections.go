@@ -0,0 +1,55 @@
+package store
+
+const (
+	VERSION_RECORDS = 1 // the count followed by the interleaved records
+	VERSION_META    = 2 // the count with its flags, then the meta section and the records in a Layout
+)
+
+/*
+Sections gives the length in bytes of each part of a store file
+*/
+type Sections struct {
+	Header  int64 `json:"header"`
+	Meta    int64 `json:"meta"`
+	Padding int64 `json:"padding"` // between the meta section and the records, to align them
+	Records int64 `json:"records"`
+	Trailer int64 `json:"trailer"` // after the records, where an index writes its delta
+}
+
+/*
+Version tells the format of the store file from the flags of its header: the files without meta section
+were written before the flags existed
+*/
+func (s Store) Version() (int, error) {
+	h, err := readHeader(s)
+	if err != nil {
+		return 0, err
+	}
+	if h.flags&META_FLAG == 0 {
+		return VERSION_RECORDS, nil
+	}
+	return VERSION_META, nil
+}
+
+/*
+Sections reads the header and returns the length of the sections of the file
+*/
+func (s Store) Sections() (sections Sections, err error) {
+	h, err := readHeader(s)
+	if err != nil {
+		return sections, err
+	}
+	sections.Header = HEADER
+	if h.flags&META_FLAG != 0 {
+		sections.Header += META_HEADER
+		sections.Meta = h.metaLen
+		sections.Padding = h.dataOffset - HEADER - META_HEADER - h.metaLen
+	}
+	sections.Records = s.RecordsLen()
+	size, err := s.Size()
+	if err != nil {
+		return sections, err
+	}
+	sections.Trailer = size - s.offset() - sections.Records
+	return sections, nil
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSections(t *testing.T) {
+	// given
+	mem := NewMemBackend(nil)
+	s, _ := Write(mem, []byte("model"), Options{PageSize: 64}, []float64{1, 2, 2.5}, []uint64{3, 4, 5})
+	mem.WriteAt(make([]byte, 16), int64(len(mem.Bytes())))
+
+	// when
+	sections, err := s.Sections()
+	version, errVersion := s.Version()
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, errVersion)
+	assert.Equal(t, VERSION_META, version)
+	assert.Equal(t, Sections{Header: 24, Meta: 5, Padding: 35, Records: 48, Trailer: 16}, sections)
+
+	// when the file has no meta section
+	old := Store{Backend: NewMemBackend(nil)}
+	old.Put(ToRecord(1, 3))
+	sections, _ = old.Sections()
+	version, _ = old.Version()
+	// then
+	assert.Equal(t, VERSION_RECORDS, version)
+	assert.Equal(t, Sections{Header: 8, Records: 16}, sections)
+}
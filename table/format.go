@@ -0,0 +1,143 @@
+package table
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+BadValues tells what to do with the values of a column that are not numbers, empty ones included.
+NaN and the infinities are bad values too, they can't be ordered among the keys
+*/
+type BadValues uint8
+
+const (
+	SKIP    BadValues = iota // the row is not indexed
+	FAIL                     // the whole column is rejected
+	DEFAULT                  // the row is indexed under the default key of the Format
+)
+
+var badValuesNames = []string{"skip", "fail", "default"}
+
+func (b BadValues) String() string {
+	if int(b) < len(badValuesNames) {
+		return badValuesNames[b]
+	}
+	return fmt.Sprintf("BadValues(%d)", b)
+}
+
+/*
+ParseBadValues returns the policy named s
+*/
+func ParseBadValues(s string) (BadValues, error) {
+	for i, n := range badValuesNames {
+		if n == s {
+			return BadValues(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown policy %q for the bad values, expected one of %s", s, strings.Join(badValuesNames, ", "))
+}
+
+/*
+Format tells how to parse the rows of a CSV file and the keys of the indexed column
+*/
+type Format struct {
+	Comma, Quote byte
+	Header       bool      // the first row names the columns
+	Field        int       // position of the column starting at 0, -1 to find it by name in the header
+	BadValues    BadValues // what to do with the values that are not numbers
+	Default      float64   // the key of the bad values with the DEFAULT policy
+}
+
+/*
+DefaultFormat returns the format of the indexes built before it was recorded:
+comma separated values with a header, the bad values indexed under 0
+*/
+func DefaultFormat() Format {
+	return Format{Comma: ',', Quote: '"', Header: true, Field: -1, BadValues: DEFAULT}
+}
+
+/*
+Column holds the keys parsed from a column of a CSV file and the pointers to their rows
+*/
+type Column struct {
+	Keys     []float64
+	Pointers []Pointer
+	Rejected int // rows skipped because of a bad value
+}
+
+/*
+NewScanner returns a Scanner reading the rows of r with the delimiter and the quote of f
+*/
+func (f Format) NewScanner(r io.Reader) *Scanner {
+	s := NewScanner(r)
+	s.Comma, s.Quote = f.Comma, f.Quote
+	return s
+}
+
+/*
+Locate reads the header row when there's one, and returns the position and the name of the column.
+The column is designated by f.Field, or else by its name, compared without case.
+Without header, the name of a column is its position
+*/
+func (f Format) Locate(s *Scanner, name string) (field int, column string, err error) {
+	if !f.Header {
+		if f.Field < 0 {
+			return 0, "", fmt.Errorf("without header, the column must be designated by its position")
+		}
+		return f.Field, strconv.Itoa(f.Field), nil
+	}
+	if !s.Scan() {
+		if s.Err() != nil {
+			return 0, "", s.Err()
+		}
+		return 0, "", fmt.Errorf("the header row is missing")
+	}
+	header := s.Row().Fields
+	if f.Field >= 0 {
+		if f.Field >= len(header) {
+			return 0, "", fmt.Errorf("the column %d is out of the %d columns of the header", f.Field, len(header))
+		}
+		return f.Field, header[f.Field], nil
+	}
+	for i, c := range header {
+		if strings.EqualFold(c, name) {
+			return i, c, nil
+		}
+	}
+	return 0, "", fmt.Errorf("no column %q in the header", name)
+}
+
+/*
+ScanColumn parses the field of the rows read by s, base is the offset in the file of the first byte read by s.
+The values that are not finite numbers are skipped, rejected or replaced by the default key according to f.BadValues
+*/
+func (f Format) ScanColumn(s *Scanner, field int, base int64) (c Column, err error) {
+	for s.Scan() {
+		row := s.Row()
+		if field >= len(row.Fields) {
+			return c, fmt.Errorf("line %d: the row has only %d fields", row.Line, len(row.Fields))
+		}
+		p, err := NewPointer(base+row.Offset, row.Length)
+		if err != nil {
+			return c, fmt.Errorf("line %d: %s", row.Line, err)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(row.Fields[field]), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			switch f.BadValues {
+			case SKIP:
+				c.Rejected++
+				continue
+			case FAIL:
+				return c, fmt.Errorf("line %d: %q is not a number", row.Line, row.Fields[field])
+			}
+			v = f.Default
+		}
+		c.Keys = append(c.Keys, v)
+		c.Pointers = append(c.Pointers, p)
+	}
+	return c, s.Err()
+}
@@ -0,0 +1,109 @@
+package table
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBadValues(t *testing.T) {
+	for _, b := range []BadValues{SKIP, FAIL, DEFAULT} {
+		// when
+		parsed, err := ParseBadValues(b.String())
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, b, parsed)
+	}
+	_, err := ParseBadValues("ignore")
+	assert.Error(t, err)
+}
+
+func TestFormat_Locate(t *testing.T) {
+	// given
+	csv := "Name;Age\njeanne;90\n"
+	f := Format{Comma: ';', Quote: '"', Header: true, Field: -1}
+
+	// when by name
+	field, name, err := f.Locate(f.NewScanner(strings.NewReader(csv)), "age")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, field)
+	assert.Equal(t, "Age", name)
+
+	// when the name is missing
+	_, _, err = f.Locate(f.NewScanner(strings.NewReader(csv)), "sex")
+	// then
+	assert.Error(t, err)
+
+	// when by position
+	f.Field = 0
+	field, name, err = f.Locate(f.NewScanner(strings.NewReader(csv)), "")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 0, field)
+	assert.Equal(t, "Name", name)
+
+	// when without header
+	f.Header, f.Field = false, 1
+	field, name, err = f.Locate(f.NewScanner(strings.NewReader(csv)), "")
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1, field)
+	assert.Equal(t, "1", name)
+}
+
+func TestFormat_ScanColumn(t *testing.T) {
+	// given
+	csv := "jeanne,90\njean,\nCarlos, 3\nMiguel,one\n"
+	f := Format{Comma: ',', Quote: '"', Field: 1}
+
+	// when the bad values are skipped
+	c, err := f.ScanColumn(f.NewScanner(strings.NewReader(csv)), 1, 0)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{90, 3}, c.Keys)
+	assert.Equal(t, []Pointer{9, 16<<LENGTH_BITS | 9}, c.Pointers)
+	assert.Equal(t, 2, c.Rejected)
+
+	// when they are replaced by the default key
+	f.BadValues, f.Default = DEFAULT, -1
+	c, err = f.ScanColumn(f.NewScanner(strings.NewReader(csv)), 1, 0)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{90, -1, 3, -1}, c.Keys)
+	assert.Equal(t, 0, c.Rejected)
+
+	// when they fail the scan
+	f.BadValues = FAIL
+	_, err = f.ScanColumn(f.NewScanner(strings.NewReader(csv)), 1, 0)
+	// then
+	assert.EqualError(t, err, `line 2: "" is not a number`)
+}
+
+func TestFormat_ScanColumn_NotFinite(t *testing.T) {
+	// given
+	csv := "jeanne,90\njean,NaN\nCarlos,+Inf\nMiguel,-inf\n"
+	f := Format{Comma: ',', Quote: '"', Field: 1}
+
+	// when
+	c, err := f.ScanColumn(f.NewScanner(strings.NewReader(csv)), 1, 0)
+	// then NaN and the infinities are bad values
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{90}, c.Keys)
+	assert.Equal(t, 3, c.Rejected)
+
+	// when
+	f.BadValues, f.Default = DEFAULT, -1
+	c, err = f.ScanColumn(f.NewScanner(strings.NewReader(csv)), 1, 0)
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{90, -1, -1, -1}, c.Keys)
+
+	// when
+	f.BadValues = FAIL
+	_, err = f.ScanColumn(f.NewScanner(strings.NewReader(csv)), 1, 0)
+	// then
+	assert.EqualError(t, err, `line 2: "NaN" is not a number`)
+}
@@ -0,0 +1,59 @@
+package table
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	LENGTH_BITS = 24 // a row is at most 16 MiB
+	OFFSET_BITS = 64 - LENGTH_BITS
+	MAX_LENGTH  = 1<<LENGTH_BITS - 1
+	MAX_OFFSET  = 1<<OFFSET_BITS - 1
+)
+
+/*
+Pointer packs the byte offset of a row in its 40 high bits and its length in the 24 low bits,
+it fits inside the uint64 value of a store.Record
+*/
+type Pointer uint64
+
+/*
+NewPointer returns the Pointer to the row starting at offset
+*/
+func NewPointer(offset, length int64) (Pointer, error) {
+	if offset < 0 || offset > MAX_OFFSET {
+		return 0, fmt.Errorf("the offset %d can't be stored on %d bits", offset, OFFSET_BITS)
+	}
+	if length < 0 || length > MAX_LENGTH {
+		return 0, fmt.Errorf("the row length %d can't be stored on %d bits", length, LENGTH_BITS)
+	}
+	return Pointer(uint64(offset)<<LENGTH_BITS | uint64(length)), nil
+}
+
+/*
+PointerTo returns the Pointer to r
+*/
+func PointerTo(r Row) (Pointer, error) {
+	return NewPointer(r.Offset, r.Length)
+}
+
+func (p Pointer) Offset() int64 {
+	return int64(p >> LENGTH_BITS)
+}
+
+func (p Pointer) Length() int64 {
+	return int64(p & MAX_LENGTH)
+}
+
+/*
+ReadAt reads the raw bytes of the row located by p, without its line terminator
+*/
+func (p Pointer) ReadAt(r io.ReaderAt) ([]byte, error) {
+	b := make([]byte, p.Length())
+	_, err := r.ReadAt(b, p.Offset())
+	if err == io.EOF && p.Length() == 0 {
+		err = nil
+	}
+	return b, err
+}
@@ -0,0 +1,42 @@
+package table
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPointer(t *testing.T) {
+	// when
+	p, err := NewPointer(1<<32+3, 250)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1<<32+3), p.Offset())
+	assert.Equal(t, int64(250), p.Length())
+
+	// when too long
+	_, err = NewPointer(0, 1<<24)
+	assert.Error(t, err)
+	// when too far
+	_, err = NewPointer(1<<40, 1)
+	assert.Error(t, err)
+}
+
+func TestPointerReadAt(t *testing.T) {
+	// given
+	csv := "name,age\njeanne,90\n\"Carlos\nthe third\",3\n"
+	s := NewScanner(strings.NewReader(csv))
+	s.Scan()
+	s.Scan()
+	s.Scan()
+	p, _ := PointerTo(s.Row())
+
+	// when
+	raw, err := p.ReadAt(strings.NewReader(csv))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "\"Carlos\nthe third\",3", string(raw))
+}
@@ -0,0 +1,146 @@
+package table
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+/*
+Row is a record of a CSV file with its position inside the file
+*/
+type Row struct {
+	Fields []string
+	Offset int64 // first byte of the row
+	Length int64 // bytes of the row without its line terminator
+	Line   int   // number of the first line of the row, starting at 1
+}
+
+/*
+Scanner reads the rows of a CSV file one by one, keeping track of their byte offsets.
+A quoted field can hold line breaks, the row then spans several lines
+*/
+type Scanner struct {
+	Comma byte // field delimiter, ',' by default
+	Quote byte // character enclosing the fields, '"' by default
+
+	r      *bufio.Reader
+	row    Row
+	err    error
+	offset int64
+	line   int
+}
+
+/*
+NewScanner returns a Scanner reading from r
+*/
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{Comma: ',', Quote: '"', r: bufio.NewReader(r), line: 1}
+}
+
+/*
+Scan advances to the next row, which is available through Row. It returns false at the end of
+the input or when a row can't be parsed, Err tells which one
+*/
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	for {
+		raw, lines, err := s.readRow()
+		if err != nil && (err != io.EOF || len(raw) == 0) {
+			s.err = err
+			return false
+		}
+		offset, line := s.offset, s.line
+		s.offset += int64(len(raw))
+		s.line += lines
+		content := trimEOL(raw)
+		if len(content) == 0 {
+			// skip the empty lines like encoding/csv
+			continue
+		}
+		fields, err := Split(content, s.Comma, s.Quote)
+		if err != nil {
+			s.err = fmt.Errorf("line %d: %s", line, err)
+			return false
+		}
+		s.row = Row{Fields: fields, Offset: offset, Length: int64(len(content)), Line: line}
+		return true
+	}
+}
+
+/*
+Row returns the row read by the last call to Scan
+*/
+func (s *Scanner) Row() Row {
+	return s.row
+}
+
+/*
+Err returns the error that stopped Scan, nil at the end of the input
+*/
+func (s *Scanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// readRow reads lines until the quotes are balanced, it returns the raw bytes and the number of line breaks
+func (s *Scanner) readRow() (raw []byte, lines int, err error) {
+	quoted := false
+	for {
+		line, err := s.r.ReadBytes('\n')
+		raw = append(raw, line...)
+		quoted = quoted != (bytes.Count(line, []byte{s.Quote})%2 == 1)
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			lines++
+		}
+		if err != nil || !quoted {
+			return raw, lines, err
+		}
+	}
+}
+
+func trimEOL(raw []byte) []byte {
+	raw = bytes.TrimSuffix(raw, []byte{'\n'})
+	return bytes.TrimSuffix(raw, []byte{'\r'})
+}
+
+/*
+Split parses the fields of a raw CSV row. A quote inside a quoted field is escaped by doubling it
+*/
+func Split(raw []byte, comma, quote byte) (fields []string, err error) {
+	field := []byte{}
+	for i := 0; i <= len(raw); i++ {
+		if i == len(raw) || raw[i] == comma {
+			fields = append(fields, string(field))
+			field = field[:0]
+			continue
+		}
+		if raw[i] != quote || len(field) > 0 {
+			field = append(field, raw[i])
+			continue
+		}
+		// quoted field
+		for i++; ; i++ {
+			if i >= len(raw) {
+				return nil, fmt.Errorf("the quoted field %q is not closed", field)
+			}
+			if raw[i] == quote && i+1 < len(raw) && raw[i+1] == quote {
+				field = append(field, quote)
+				i++
+			} else if raw[i] == quote {
+				break
+			} else {
+				field = append(field, raw[i])
+			}
+		}
+		if i+1 < len(raw) && raw[i+1] != comma {
+			return nil, fmt.Errorf("unexpected %q after the quoted field %q", raw[i+1], field)
+		}
+	}
+	return fields, nil
+}
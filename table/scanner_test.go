@@ -0,0 +1,71 @@
+package table
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanner(t *testing.T) {
+	// given
+	csv := "name,age\njeanne,90\r\n\n\"Carlos\nthe third\",3\n\"Martine \"\"la grande\"\"\",1.5"
+	s := NewScanner(strings.NewReader(csv))
+
+	// when
+	rows := []Row{}
+	for s.Scan() {
+		rows = append(rows, s.Row())
+	}
+
+	// then
+	assert.NoError(t, s.Err())
+	assert.Equal(t, []Row{
+		{Fields: []string{"name", "age"}, Offset: 0, Length: 8, Line: 1},
+		{Fields: []string{"jeanne", "90"}, Offset: 9, Length: 9, Line: 2},
+		{Fields: []string{"Carlos\nthe third", "3"}, Offset: 21, Length: 20, Line: 4},
+		{Fields: []string{"Martine \"la grande\"", "1.5"}, Offset: 42, Length: 27, Line: 6},
+	}, rows)
+	assert.Equal(t, "\"Carlos\nthe third\",3", csv[21:21+20])
+}
+
+func TestScanner_Delimiter(t *testing.T) {
+	// given
+	s := NewScanner(strings.NewReader("a;'b;c'\n"))
+	s.Comma, s.Quote = ';', '\''
+
+	// when
+	ok := s.Scan()
+
+	// then
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a", "b;c"}, s.Row().Fields)
+	assert.False(t, s.Scan())
+	assert.NoError(t, s.Err())
+}
+
+func TestScanner_Error(t *testing.T) {
+	// given
+	s := NewScanner(strings.NewReader("a,b\n\"c\"d,e\n"))
+
+	// when
+	s.Scan()
+	ok := s.Scan()
+
+	// then
+	assert.False(t, ok)
+	assert.EqualError(t, s.Err(), "line 2: unexpected 'd' after the quoted field \"c\"")
+}
+
+func TestSplit(t *testing.T) {
+	fields, err := Split([]byte(`1,"Braund, Mr. Owen Harris",,22`), ',', '"')
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "Braund, Mr. Owen Harris", "", "22"}, fields)
+
+	fields, err = Split([]byte(`"",a"b`), ',', '"')
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", `a"b`}, fields)
+
+	_, err = Split([]byte(`"abc`), ',', '"')
+	assert.Error(t, err)
+}